@@ -2,12 +2,15 @@ package main
 
 import (
 	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
 
 	"github.com/rs/zerolog/log"
 )
 
 func main() {
 	s := server.New()
+	stopReaper := trade.StartExpiryReaper()
+	defer stopReaper()
 	err := s.Start(":8080")
 	if err != nil {
 		log.Err(err).Send()
@@ -1,11 +1,17 @@
 package server
 
 import (
+	"net"
 	"os"
+	"strings"
+	"trading-bsx/internal/account"
 	"trading-bsx/internal/middleware"
 	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/auth"
 	"trading-bsx/pkg/db/mongodb"
 	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/market"
+	"trading-bsx/pkg/ratelimit"
 	"trading-bsx/pkg/utils"
 
 	"github.com/joho/godotenv"
@@ -26,18 +32,87 @@ func New() *echo.Echo {
 		Out: os.Stdout,
 	})
 
-	rocksdb.Init()
+	market.Init()
+	auth.Init()
+	ratelimit.Init()
+	rocksdb.Init(market.Symbols())
 	mongodb.Init()
+	trade.Init()
 
 	e := echo.New()
 	e.HTTPErrorHandler = utils.HttpErrorHandler
 	e.Validator = utils.NewValidator()
-	e.Use(middleware.VerifyUser)
+	e.IPExtractor = ipExtractor()
+	e.Use(middleware.LimitIP)
 
-	order := e.Group("/orders")
+	order := e.Group("/orders", middleware.VerifyUserOrApiKey, middleware.LimitUser)
 	order.GET("", trade.GetOrders)
 	order.POST("", trade.PlaceOrder)
+	order.DELETE("", trade.CancelOrders)
+	order.GET("/:order_id", trade.GetOrder)
+	order.PATCH("/:order_id", trade.AmendOrder)
 	order.DELETE("/:order_id", trade.CancelOrder)
+	order.GET("/client/:client_order_id", trade.GetOrder)
+	order.DELETE("/client/:client_order_id", trade.CancelOrder)
+
+	marketOrder := e.Group("/markets/:symbol/orders", middleware.VerifyUserOrApiKey, middleware.LimitUser)
+	marketOrder.GET("", trade.GetOrders)
+	marketOrder.POST("", trade.PlaceOrder)
+	marketOrder.DELETE("", trade.CancelOrders)
+	marketOrder.GET("/:order_id", trade.GetOrder)
+	marketOrder.PATCH("/:order_id", trade.AmendOrder)
+	marketOrder.DELETE("/:order_id", trade.CancelOrder)
+	marketOrder.GET("/client/:client_order_id", trade.GetOrder)
+	marketOrder.DELETE("/client/:client_order_id", trade.CancelOrder)
+
+	accountGroup := e.Group("/account", middleware.VerifyUserOrApiKey, middleware.LimitUser)
+	accountGroup.GET("", account.GetAccount)
+	accountGroup.PATCH("", account.UpdateAccount)
+	accountGroup.GET("/balances", trade.GetBalances)
+	accountGroup.POST("/deposits", trade.Deposit)
+	// Funds are withdrawn with a JWT only, so that a leaked API key can't move them out
+	e.POST("/account/withdrawals", trade.Withdraw, middleware.VerifyUser, middleware.LimitUser)
+
+	// API keys are managed with a JWT only, so that a leaked key can't create others
+	apiKeyGroup := e.Group("/api-keys", middleware.VerifyUser, middleware.LimitUser)
+	apiKeyGroup.GET("", account.GetApiKeys)
+	apiKeyGroup.POST("", account.CreateApiKey)
+	apiKeyGroup.DELETE("/:id", account.RevokeApiKey)
+
+	e.GET("/trades", trade.GetTrades, middleware.VerifyUserOrApiKey, middleware.LimitUser)
+	e.GET("/ledger", trade.GetLedger, middleware.VerifyUserOrApiKey, middleware.LimitUser)
+	e.GET("/markets/trades", trade.GetMarketTrades)
+	e.GET("/markets/:symbol/rules", trade.GetMarketRules)
+	e.GET("/orderbook", trade.GetOrderBook)
+	e.GET("/markets/:symbol/orderbook", trade.GetOrderBook)
+	e.GET("/ticker", trade.GetTicker)
+	e.GET("/markets/:symbol/ticker", trade.GetTicker)
+	e.GET("/candlesticks", trade.GetCandlesticks)
+	e.GET("/markets/:symbol/candlesticks", trade.GetCandlesticks)
+	e.GET("/ws", trade.MarketStream)
+	e.GET("/ws/user", trade.UserStream, middleware.VerifyUserOrApiKey, middleware.LimitUser)
 
 	return e
 }
+
+// ipExtractor reads the client IP from X-Forwarded-For only when sent by a proxy of TRUSTED_PROXIES,
+// a comma-separated list of CIDRs. Otherwise the IP is the peer address, which clients can't spoof.
+func ipExtractor() echo.IPExtractor {
+	proxies := os.Getenv("TRUSTED_PROXIES")
+	if len(proxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, cidr := range strings.Split(proxies, ",") {
+		_, ipRange, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			panic(err)
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
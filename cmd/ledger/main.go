@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/ledger"
+	"trading-bsx/pkg/market"
+
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Verifies the ledger against the balances. The server must be stopped, as it holds the RocksDB lock.
+// It prints the report and exits with 1 if the ledger is inconsistent.
+func main() {
+	godotenv.Load()
+	log.Logger = log.Output(zerolog.ConsoleWriter{
+		Out: os.Stderr,
+	})
+
+	market.Init()
+	rocksdb.Init(market.Symbols())
+	defer rocksdb.DB.Close()
+
+	report, err := ledger.Verify()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Verify ledger")
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+	if len(report.Errors) > 0 {
+		log.Error().Int("errors", len(report.Errors)).Msg("Ledger is inconsistent")
+		os.Exit(1)
+	}
+	log.Info().Uint64("entries", report.Entries).Msg("Ledger is consistent")
+}
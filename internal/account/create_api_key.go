@@ -0,0 +1,80 @@
+package account
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/mongodb"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Max active API keys of a user
+const MaxApiKeys = 20
+
+type CreateApiKeyBody struct {
+	Name        string                    `json:"name" validate:"max=64"`
+	Permissions []models.ApiKeyPermission `json:"permissions" validate:"required,min=1,dive,oneof=READ TRADE"`
+}
+
+// CreatedApiKey is a new API key with its secret, which is never returned again
+type CreatedApiKey struct {
+	models.ApiKey
+	Secret string `json:"secret"`
+}
+
+func CreateApiKey(c echo.Context) error {
+	body := CreateApiKeyBody{}
+	if err := utils.BindNValidate(c, &body); err != nil {
+		return err
+	}
+	ctx := c.Request().Context()
+	userId := c.Get("userId").(uint64)
+
+	count, err := mongodb.ApiKey.CountDocuments(ctx, bson.M{
+		"user_id":    userId,
+		"revoked_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return err
+	}
+	if count >= MaxApiKeys {
+		return echo.NewHTTPError(http.StatusBadRequest, "A user can't have more than "+strconv.Itoa(MaxApiKeys)+" API keys")
+	}
+
+	key, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+	apiKey := models.ApiKey{
+		ID:          primitive.NewObjectID(),
+		UserId:      userId,
+		Name:        body.Name,
+		Key:         key,
+		Secret:      secret,
+		Permissions: body.Permissions,
+		CreatedAt:   uint64(time.Now().UnixNano()),
+	}
+	if _, err := mongodb.ApiKey.InsertOne(ctx, apiKey); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, CreatedApiKey{ApiKey: apiKey, Secret: secret})
+}
+
+func randomHex(size int) (string, error) {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
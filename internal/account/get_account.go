@@ -0,0 +1,34 @@
+package account
+
+import (
+	"context"
+	"net/http"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/mongodb"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func GetAccount(c echo.Context) error {
+	account, err := FindAccount(c.Request().Context(), c.Get("userId").(uint64))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, account)
+}
+
+// FindAccount returns the settings of a user. Users without saved settings get the defaults.
+func FindAccount(ctx context.Context, userId uint64) (models.Account, error) {
+	account := models.Account{}
+	err := mongodb.Account.FindOne(ctx, bson.M{"user_id": userId}).Decode(&account)
+	if err == mongo.ErrNoDocuments {
+		err = nil
+	}
+	account.UserId = userId
+	if account.SelfTradePrevention == "" {
+		account.SelfTradePrevention = models.DEFAULT_STP_MODE
+	}
+	return account, err
+}
@@ -0,0 +1,28 @@
+package account
+
+import (
+	"net/http"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/mongodb"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetApiKeys lists the API keys of the user, newest first, revoked keys included. Secrets are not returned.
+func GetApiKeys(c echo.Context) error {
+	ctx := c.Request().Context()
+	cursor, err := mongodb.ApiKey.Find(ctx,
+		bson.M{"user_id": c.Get("userId").(uint64)},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	)
+	if err != nil {
+		return err
+	}
+	apiKeys := make([]models.ApiKey, 0)
+	if err := cursor.All(ctx, &apiKeys); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, apiKeys)
+}
@@ -0,0 +1,41 @@
+package account
+
+import (
+	"net/http"
+	"time"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/mongodb"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RevokeApiKeyParam struct {
+	ID primitive.ObjectID `param:"id" validate:"required"`
+}
+
+// RevokeApiKey revokes an API key of the user, whose requests are rejected from then on
+func RevokeApiKey(c echo.Context) error {
+	param := RevokeApiKeyParam{}
+	if err := utils.BindNValidate(c, &param); err != nil {
+		return err
+	}
+
+	apiKey := models.ApiKey{}
+	if err := mongodb.ApiKey.FindOneAndUpdate(
+		c.Request().Context(),
+		bson.M{
+			"_id":        param.ID,
+			"user_id":    c.Get("userId").(uint64),
+			"revoked_at": bson.M{"$exists": false},
+		},
+		bson.M{"$set": bson.M{"revoked_at": uint64(time.Now().UnixNano())}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&apiKey); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, apiKey)
+}
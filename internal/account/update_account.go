@@ -0,0 +1,35 @@
+package account
+
+import (
+	"net/http"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/mongodb"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type UpdateAccountBody struct {
+	SelfTradePrevention models.STPMode `json:"selfTradePrevention" validate:"required,oneof=CANCEL_NEWEST CANCEL_OLDEST CANCEL_BOTH DECREMENT_AND_CANCEL ALLOW"`
+}
+
+func UpdateAccount(c echo.Context) error {
+	body := UpdateAccountBody{}
+	if err := utils.BindNValidate(c, &body); err != nil {
+		return err
+	}
+	userId := c.Get("userId").(uint64)
+
+	account := models.Account{}
+	if err := mongodb.Account.FindOneAndUpdate(
+		c.Request().Context(),
+		bson.M{"user_id": userId},
+		bson.M{"$set": bson.M{"self_trade_prevention": body.SelfTradePrevention}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&account); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, account)
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"trading-bsx/pkg/ratelimit"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+)
+
+// requestWeight is the weight of placing, cancelling or querying orders
+func requestWeight(method string) float64 {
+	weights := ratelimit.Get().Weights
+	switch method {
+	case http.MethodPost, http.MethodPatch, http.MethodPut:
+		return weights.Place
+	case http.MethodDelete:
+		return weights.Cancel
+	}
+	return weights.Query
+}
+
+type limitedKey struct {
+	scope   string
+	limiter *ratelimit.Limiter
+	key     string
+}
+
+// LimitIP limits the rate of requests of each IP, before they are authenticated
+func LimitIP(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return limit(c, next, []limitedKey{
+			{scope: "ip", limiter: ratelimit.IPLimiter, key: c.RealIP()},
+		})
+	}
+}
+
+// LimitUser limits the rate of requests of each user & API key. It must follow the authentication.
+func LimitUser(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		keys := []limitedKey{
+			{scope: "user", limiter: ratelimit.UserLimiter, key: strconv.FormatUint(c.Get("userId").(uint64), 10)},
+		}
+		if apiKey, ok := c.Get("apiKey").(string); ok {
+			keys = append(keys, limitedKey{scope: "apiKey", limiter: ratelimit.ApiKeyLimiter, key: apiKey})
+		}
+		return limit(c, next, keys)
+	}
+}
+
+// limit takes the weight of the request from the buckets of its keys, and sets the headers of the most restrictive one.
+// A request rejected by a bucket gets back what it took from the others.
+func limit(c echo.Context, next echo.HandlerFunc, keys []limitedKey) error {
+	weight := requestWeight(c.Request().Method)
+	now := time.Now()
+
+	var strictest *ratelimit.Result
+	taken := make([]limitedKey, 0, len(keys))
+	for _, key := range keys {
+		if !key.limiter.Enabled() {
+			continue
+		}
+		result := key.limiter.Take(key.key, weight, now)
+		if !result.Allowed {
+			for _, refunded := range taken {
+				refunded.limiter.Refund(refunded.key, weight)
+			}
+			setRateLimitHeaders(c, &result)
+			retryAfter := seconds(result.Reset)
+			c.Response().Header().Set(echo.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
+			return c.JSON(http.StatusTooManyRequests, utils.ErrResponse{
+				Message: "Rate limit exceeded",
+				Metadata: map[string]interface{}{
+					"scope":      key.scope,
+					"retryAfter": retryAfter,
+				},
+			})
+		}
+		taken = append(taken, key)
+		if strictest == nil || result.Remaining/result.Limit < strictest.Remaining/strictest.Limit {
+			strictest = &result
+		}
+	}
+	if strictest != nil {
+		setRateLimitHeaders(c, strictest)
+	}
+	return next(c)
+}
+
+func setRateLimitHeaders(c echo.Context, result *ratelimit.Result) {
+	header := c.Response().Header()
+	header.Set(HeaderRateLimitLimit, strconv.FormatInt(int64(result.Limit), 10))
+	header.Set(HeaderRateLimitRemaining, strconv.FormatInt(int64(math.Floor(result.Remaining)), 10))
+	header.Set(HeaderRateLimitReset, strconv.FormatInt(seconds(result.Reset), 10))
+}
+
+// seconds rounds the duration up to whole seconds
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"trading-bsx/pkg/auth"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/mongodb"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Max difference between the timestamp of a signed request & the server time
+const ReplayWindow = 5 * time.Second
+
+// Signatures accepted within the replay window, so that a captured request can't be sent again
+var seenSignatures = map[string]time.Time{}
+var seenMutex sync.Mutex
+var lastPurge time.Time
+
+// VerifyApiKey authenticates requests signed with an API key. GET requests need the READ permission, others TRADE.
+func VerifyApiKey(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		key := req.Header.Get(auth.HeaderApiKey)
+		timestamp := req.Header.Get(auth.HeaderApiTimestamp)
+		signature := req.Header.Get(auth.HeaderApiSignature)
+		if len(key) == 0 || len(timestamp) == 0 || len(signature) == 0 {
+			return echo.ErrUnauthorized
+		}
+
+		ms, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid timestamp")
+		}
+		now := time.Now()
+		signedAt := time.UnixMilli(ms)
+		if signedAt.Before(now.Add(-ReplayWindow)) || signedAt.After(now.Add(ReplayWindow)) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Timestamp is outside of the replay window")
+		}
+
+		apiKey := models.ApiKey{}
+		err = mongodb.ApiKey.FindOne(req.Context(), bson.M{
+			"key":        key,
+			"revoked_at": bson.M{"$exists": false},
+		}).Decode(&apiKey)
+		if err == mongo.ErrNoDocuments {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid API key")
+		} else if err != nil {
+			return err
+		}
+
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		expected := auth.Sign(apiKey.Secret, timestamp, req.Method, req.RequestURI, body)
+		if !hmac.Equal([]byte(expected), []byte(signature)) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Invalid signature")
+		}
+		if !markSignature(signature, signedAt.Add(ReplayWindow)) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Request is replayed")
+		}
+
+		permission := models.TRADE
+		if req.Method == http.MethodGet {
+			permission = models.READ
+		}
+		if !apiKey.Allows(permission) {
+			return echo.NewHTTPError(http.StatusForbidden, "API key lacks the "+string(permission)+" permission")
+		}
+
+		c.Set("userId", apiKey.UserId)
+		c.Set("apiKey", apiKey.Key)
+
+		return next(c)
+	}
+}
+
+// VerifyUserOrApiKey authenticates requests signed with an API key, or else with a JWT
+func VerifyUserOrApiKey(next echo.HandlerFunc) echo.HandlerFunc {
+	withApiKey := VerifyApiKey(next)
+	withUser := VerifyUser(next)
+	return func(c echo.Context) error {
+		if len(c.Request().Header.Get(auth.HeaderApiKey)) > 0 {
+			return withApiKey(c)
+		}
+		return withUser(c)
+	}
+}
+
+// markSignature records a signature until it expires, and reports whether it is new
+func markSignature(signature string, expiresAt time.Time) bool {
+	seenMutex.Lock()
+	defer seenMutex.Unlock()
+	if now := time.Now(); now.Sub(lastPurge) > ReplayWindow {
+		for seen, expiry := range seenSignatures {
+			if expiry.Before(now) {
+				delete(seenSignatures, seen)
+			}
+		}
+		lastPurge = now
+	}
+	if _, ok := seenSignatures[signature]; ok {
+		return false
+	}
+	seenSignatures[signature] = expiresAt
+	return true
+}
@@ -1,7 +1,9 @@
 package middleware
 
 import (
-	"strconv"
+	"net/http"
+	"strings"
+	"trading-bsx/pkg/auth"
 
 	"github.com/labstack/echo/v4"
 )
@@ -9,14 +11,19 @@ import (
 func VerifyUser(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		// Get the user ID from the JWT
-		authHeader := c.Request().Header.Get("Authorization")
-		if len(authHeader) == 0 {
+		authHeader := c.Request().Header.Get(echo.HeaderAuthorization)
+		token, ok := strings.CutPrefix(authHeader, "Bearer ")
+		if !ok || len(token) == 0 {
 			return echo.ErrUnauthorized
 		}
 
-		userId, err := strconv.ParseUint(authHeader, 10, 64)
+		claims, err := auth.Verify(token)
 		if err != nil {
-			return echo.ErrUnauthorized
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+		userId, err := claims.UserId()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
 		}
 
 		c.Set("userId", userId)
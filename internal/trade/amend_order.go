@@ -0,0 +1,168 @@
+package trade
+
+import (
+	"encoding/base32"
+	"net/http"
+	"time"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/mongodb"
+	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/events"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/linxGnu/grocksdb"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type UpdateOrder struct {
+	Symbol  string             `param:"symbol" validate:"omitempty,valid_symbol"`
+	OrderId primitive.ObjectID `param:"order_id" validate:"required"`
+	// New limit price
+	Price models.Decimal `json:"price" validate:"omitempty,price"`
+	// New total quantity, filled quantity included
+	Quantity models.Decimal `json:"quantity" validate:"omitempty,quantity"`
+	// New expire time, as Unix timestamp in milliseconds
+	ExpireTime *uint64 `json:"expireTime,omitempty" validate:"omitempty,gt=0"`
+}
+
+// AmendOrder changes the price, quantity or expiration time of a resting order.
+// The order keeps its time priority, unless its price changes or its quantity increases.
+func AmendOrder(c echo.Context) error {
+	body := UpdateOrder{}
+	if err := utils.BindNValidate(c, &body); err != nil {
+		return err
+	}
+	if body.Price.IsZero() && body.Quantity.IsZero() && body.ExpireTime == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Price, quantity or expire time is required")
+	}
+	userId := c.Get("userId").(uint64)
+	reqCtx := c.Request().Context()
+
+	filter := bson.M{
+		"_id":     body.OrderId,
+		"user_id": userId,
+		"status":  bson.M{"$in": models.OpenStatuses},
+	}
+	if len(body.Symbol) > 0 {
+		filter["symbol"] = body.Symbol
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	order := models.Order{}
+	if err := mongodb.Order.FindOne(reqCtx, filter).Decode(&order); err != nil {
+		return err
+	}
+	ts := uint64(time.Now().UnixNano())
+	if order.IsExpired(ts) {
+		return echo.NewHTTPError(http.StatusNotFound, "Order is expired")
+	}
+
+	amended := order
+	keepPriority := true
+	if !body.Price.IsZero() && !body.Price.Equal(order.Price) {
+		amended.Price = body.Price
+		keepPriority = false
+	}
+	if !body.Quantity.IsZero() {
+		filled := order.Quantity.Sub(order.Remaining)
+		if body.Quantity.Cmp(filled) <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "Quantity must be greater than the filled quantity "+filled.String())
+		}
+		amended.Quantity = body.Quantity
+		amended.Remaining = body.Quantity.Sub(filled)
+		keepPriority = keepPriority && body.Quantity.Cmp(order.Quantity) <= 0
+	}
+	if body.ExpireTime != nil {
+		expiredAt, err := parseExpireTime(*body.ExpireTime, ts)
+		if err != nil {
+			return err
+		}
+		amended.ExpiredAt = &expiredAt
+		if amended.TimeInForce == models.GTC {
+			amended.TimeInForce = models.GTD
+		}
+	}
+	if !keepPriority {
+		// Back of the queue at the new price
+		amended.Timestamp = ts
+	}
+	if err := checkMarketRules(reqCtx, &amended); err != nil {
+		return err
+	}
+
+	// An amended order only rests on the book, it never takes liquidity
+	orderBook := rocksdb.Book(order.Symbol)
+	var matchOrders []bookOrder
+	if order.Type == models.BUY {
+		matchOrders, _ = getMatchSellOrder(orderBook, &amended)
+	} else {
+		matchOrders, _ = getMatchBuyOrder(orderBook, &amended)
+	}
+	if len(matchOrders) > 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Amended order would match resting orders, cancel it & place a new order instead")
+	}
+
+	wo := grocksdb.NewDefaultWriteOptions()
+	defer wo.Destroy()
+	batch := grocksdb.NewWriteBatch()
+	defer batch.Destroy()
+
+	// The funds of the order are locked again for the amended price & quantity
+	orderKey, _ := base32.StdEncoding.DecodeString(order.Key)
+	balances := balanceUpdates{}
+	onBook, err := balances.releaseBookOrder(order.Symbol, order.Type, orderKey)
+	if err != nil {
+		return err
+	}
+	if !onBook {
+		return echo.NewHTTPError(http.StatusNotFound, "Order is not on the book")
+	}
+	if err := balances.lockOrder(&amended); err != nil {
+		return err
+	}
+	if err := balances.write(batch); err != nil {
+		return err
+	}
+	orderBook.Delete(batch, &order, orderKey)
+	orderBook.Put(batch, &amended)
+	if err := rocksdb.DB.Write(wo, batch); err != nil {
+		return err
+	}
+	if amended.ExpiredAt != nil {
+		wakeExpiryReaper()
+	}
+
+	fields := bson.M{
+		"price":         amended.Price,
+		"quantity":      amended.Quantity,
+		"remaining":     amended.Remaining,
+		"time_in_force": amended.TimeInForce,
+		"timestamp":     amended.Timestamp,
+		"key":           amended.Key,
+	}
+	if amended.ExpiredAt != nil {
+		fields["expired_at"] = *amended.ExpiredAt
+	}
+	result := models.Order{}
+	if err := mongodb.Order.FindOneAndUpdate(reqCtx, bson.M{"_id": order.ID}, bson.M{
+		"$set": fields,
+		"$inc": bson.M{"version": 1},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&result); err != nil {
+		// The amended order is on the book, so the request doesn't fail
+		log.Err(err).Interface("order", amended).Msg("Store amended order")
+		result = amended
+		result.Version++
+	}
+
+	publishUserEvents(reqCtx, []events.Event{orderEvent(events.ORDER_AMENDED, &result, ts)})
+	publishBookUpdate(reqCtx, order.Symbol, []models.Order{order, amended})
+
+	log.Info().Interface("order", result).Bool("keepPriority", keepPriority).Msg("Amend order")
+	return c.JSON(http.StatusOK, result)
+}
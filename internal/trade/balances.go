@@ -0,0 +1,280 @@
+package trade
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/ledger"
+	"trading-bsx/pkg/market"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/linxGnu/grocksdb"
+	"github.com/rs/zerolog/log"
+)
+
+// balanceUpdates accumulates the balance changes written with a batch, loading each balance once.
+// The matching mutex must be locked from the first read until the batch is written.
+type balanceUpdates map[string]*models.Balance
+
+func (u balanceUpdates) get(userId uint64, asset string) (*models.Balance, error) {
+	key := strconv.FormatUint(userId, 10) + "/" + asset
+	if balance, ok := u[key]; ok {
+		return balance, nil
+	}
+	balance, err := rocksdb.GetBalance(userId, asset)
+	if err != nil {
+		return nil, err
+	}
+	u[key] = &balance
+	return &balance, nil
+}
+
+// credit adds the amount to the available funds
+func (u balanceUpdates) credit(userId uint64, asset string, amount models.Decimal) error {
+	balance, err := u.get(userId, asset)
+	if err != nil {
+		return err
+	}
+	balance.Available = balance.Available.Add(amount)
+	return nil
+}
+
+// debit takes the amount from the available funds
+func (u balanceUpdates) debit(userId uint64, asset string, amount models.Decimal) error {
+	balance, err := u.get(userId, asset)
+	if err != nil {
+		return err
+	}
+	if balance.Available.Cmp(amount) < 0 {
+		return insufficientBalance(balance, amount)
+	}
+	balance.Available = balance.Available.Sub(amount)
+	return nil
+}
+
+// lock moves the amount from the available to the locked funds
+func (u balanceUpdates) lock(userId uint64, asset string, amount models.Decimal) error {
+	if err := u.debit(userId, asset, amount); err != nil {
+		return err
+	}
+	balance, _ := u.get(userId, asset)
+	balance.Locked = balance.Locked.Add(amount)
+	return nil
+}
+
+// unlock moves the amount from the locked to the available funds
+func (u balanceUpdates) unlock(userId uint64, asset string, amount models.Decimal) error {
+	balance, err := u.get(userId, asset)
+	if err != nil {
+		return err
+	}
+	if balance.Locked.Cmp(amount) < 0 {
+		// Never expected, the locked funds back the open orders
+		log.Error().Interface("balance", balance).Stringer("amount", amount).Msg("Unlocked more than the locked funds")
+		amount = balance.Locked
+	}
+	balance.Locked = balance.Locked.Sub(amount)
+	balance.Available = balance.Available.Add(amount)
+	return nil
+}
+
+// lockOrder locks the funds backing the remaining quantity of an order resting on the book
+func (u balanceUpdates) lockOrder(order *models.Order) error {
+	asset, amount := orderLock(order, order.Remaining)
+	return u.lock(order.UserId, asset, amount)
+}
+
+// reduceOrder unlocks the funds no longer backing a resting order, whose remaining quantity was before
+func (u balanceUpdates) reduceOrder(order *models.Order, before models.Decimal) error {
+	asset, locked := orderLock(order, before)
+	_, remaining := orderLock(order, order.Remaining)
+	return u.unlock(order.UserId, asset, locked.Sub(remaining))
+}
+
+// releaseOrder unlocks the funds of a resting order removed from the book
+func (u balanceUpdates) releaseOrder(order *models.Order) error {
+	asset, amount := orderLock(order, order.Remaining)
+	return u.unlock(order.UserId, asset, amount)
+}
+
+// releaseBookOrder unlocks the funds of the order stored under the key, if it is still on the book.
+// It reports whether the order was on the book.
+// The remaining quantity is read from the book, which is up to date under the matching mutex.
+func (u balanceUpdates) releaseBookOrder(symbol string, side models.OrderType, key []byte) (bool, error) {
+	ro := grocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+	value, err := rocksdb.DB.GetCF(ro, rocksdb.Book(symbol).Side(side), key)
+	if err != nil {
+		return false, err
+	}
+	defer value.Free()
+	if !value.Exists() {
+		return false, nil
+	}
+	order := models.Order{Symbol: symbol, Type: side}
+	order.ParseKV(key, value.Data())
+	return true, u.releaseOrder(&order)
+}
+
+// settleFill exchanges the funds of a fill between the taker & the resting maker order, whose remaining quantity was before,
+// and records it in the journal. The maker pays from its locked funds, the taker from its available funds.
+func (u balanceUpdates) settleFill(journal *ledger.Journal, fill *models.Trade, maker *models.Order, before models.Decimal) error {
+	// The maker's funds are unlocked first. They may exceed the value by the rounding of the lock, which stays available.
+	if err := u.reduceOrder(maker, before); err != nil {
+		return err
+	}
+	tx := ledger.Fill(fill)
+	if err := u.apply(tx); err != nil {
+		return err
+	}
+	return journal.Record(tx)
+}
+
+// apply changes the available funds of users by the entries of a ledger transaction
+func (u balanceUpdates) apply(tx *ledger.Transaction) error {
+	for _, entry := range tx.Entries {
+		userId, ok := entry.Account.UserId()
+		if !ok {
+			continue
+		}
+		var err error
+		if entry.Credit.Sign() > 0 {
+			err = u.credit(userId, entry.Asset, entry.Credit)
+		} else {
+			err = u.debit(userId, entry.Asset, entry.Debit)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write writes the changed balances with the batch
+func (u balanceUpdates) write(batch *grocksdb.WriteBatch) error {
+	for _, balance := range u {
+		if err := rocksdb.PutBalance(batch, balance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderLock returns the asset & the amount locked by an order with the remaining quantity:
+// the quote value of a buy order at its price, the base quantity of a sell order
+func orderLock(order *models.Order, remaining models.Decimal) (string, models.Decimal) {
+	m := market.Get(order.Symbol)
+	if order.Type == models.BUY {
+		return m.Quote, order.Price.Mul(remaining)
+	}
+	return m.Base, remaining
+}
+
+func insufficientBalance(balance *models.Balance, amount models.Decimal) *utils.ValidationError {
+	return &utils.ValidationError{
+		Message: fmt.Sprintf("Insufficient %s balance", balance.Asset),
+		Metadata: map[string]interface{}{
+			"asset":     balance.Asset,
+			"available": balance.Available.String(),
+			"required":  amount.String(),
+		},
+	}
+}
+
+type GetBalancesQuery struct {
+	Asset string `query:"asset" validate:"omitempty,valid_wallet_type"`
+}
+
+// GetBalances returns the available & locked funds of the user in each asset
+func GetBalances(c echo.Context) error {
+	req := GetBalancesQuery{}
+	if err := utils.BindNValidate(c, &req); err != nil {
+		return err
+	}
+	userId := c.Get("userId").(uint64)
+	if len(req.Asset) > 0 {
+		balance, err := rocksdb.GetBalance(userId, req.Asset)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, []models.Balance{balance})
+	}
+	balances, err := rocksdb.UserBalances(userId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, balances)
+}
+
+type Transfer struct {
+	Asset  string         `json:"asset" validate:"required,valid_wallet_type"`
+	Amount models.Decimal `json:"amount" validate:"quantity"`
+}
+
+// TransferFunds moves available funds in or out of the account of a user, returning the new balance
+func TransferFunds(userId uint64, asset string, amount models.Decimal, withdrawal bool) (models.Balance, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	balances := balanceUpdates{}
+	journal := ledger.Journal{}
+	tx := ledger.Transfer(userId, asset, amount, withdrawal, uint64(time.Now().UnixNano()))
+	if err := balances.apply(tx); err != nil {
+		return models.Balance{}, err
+	}
+	if err := journal.Record(tx); err != nil {
+		return models.Balance{}, err
+	}
+
+	wo := grocksdb.NewDefaultWriteOptions()
+	defer wo.Destroy()
+	batch := grocksdb.NewWriteBatch()
+	defer batch.Destroy()
+	if err := balances.write(batch); err != nil {
+		return models.Balance{}, err
+	}
+	if err := journal.Write(batch); err != nil {
+		return models.Balance{}, err
+	}
+	if err := rocksdb.DB.Write(wo, batch); err != nil {
+		return models.Balance{}, err
+	}
+	balance, _ := balances.get(userId, asset)
+	log.Info().Interface("balance", balance).Stringer("amount", amount).Bool("withdrawal", withdrawal).Msg("Transfer funds")
+	return *balance, nil
+}
+
+// Deposit credits the available funds of the user. It stands in for the deposits of a wallet integration,
+// and is only enabled by DEPOSITS_ENABLED=true, e.g. in development.
+func Deposit(c echo.Context) error {
+	if os.Getenv("DEPOSITS_ENABLED") != "true" {
+		return echo.NewHTTPError(http.StatusForbidden, "Deposits are disabled")
+	}
+	body := Transfer{}
+	if err := utils.BindNValidate(c, &body); err != nil {
+		return err
+	}
+	balance, err := TransferFunds(c.Get("userId").(uint64), body.Asset, body.Amount, false)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, balance)
+}
+
+// Withdraw takes available funds from the account of the user. Locked funds can't be withdrawn.
+func Withdraw(c echo.Context) error {
+	body := Transfer{}
+	if err := utils.BindNValidate(c, &body); err != nil {
+		return err
+	}
+	balance, err := TransferFunds(c.Get("userId").(uint64), body.Asset, body.Amount, true)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, balance)
+}
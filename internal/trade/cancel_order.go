@@ -2,11 +2,12 @@ package trade
 
 import (
 	"encoding/base32"
-	"fmt"
 	"net/http"
+	"time"
 	"trading-bsx/pkg/db/models"
 	"trading-bsx/pkg/db/mongodb"
 	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/events"
 	"trading-bsx/pkg/utils"
 
 	"github.com/labstack/echo/v4"
@@ -17,41 +18,75 @@ import (
 )
 
 type DeleteOrder struct {
-	OrderId primitive.ObjectID    `param:"order_id" validate:"required"`
+	Symbol        string             `param:"symbol" validate:"omitempty,valid_symbol"`
+	OrderId       primitive.ObjectID `param:"order_id" validate:"required_without=ClientOrderId"`
+	ClientOrderId string             `param:"client_order_id" validate:"omitempty,client_order_id"`
 }
 
 func CancelOrder(c echo.Context) error {
 	req := DeleteOrder{}
 	if err := utils.BindNValidate(c, &req); err != nil {
-		fmt.Println(err)
+		log.Error().Err(err).Msg("Invalid cancel request")
 		return err
 	}
 	userId := c.Get("userId").(uint64)
 
-	order := models.Order{}
-	if err := mongodb.Order.FindOneAndDelete(c.Request().Context(), bson.M{
-		"_id": req.OrderId,
+	filter := bson.M{
 		"user_id": userId,
-	}).Decode(&order); err != nil {
-		return err
+		"status":  bson.M{"$in": models.OpenStatuses},
 	}
-
-	var book *grocksdb.DB
-	if order.Type == models.BUY {
-		book = rocksdb.BuyOrder
+	if len(req.ClientOrderId) > 0 {
+		filter["client_order_id"] = req.ClientOrderId
 	} else {
-		book = rocksdb.SellOrder
+		filter["_id"] = req.OrderId
+	}
+	if len(req.Symbol) > 0 {
+		filter["symbol"] = req.Symbol
+	}
+
+	// Locked before the lookup, so that the order can't be matched once it is found open
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	order := models.Order{}
+	if err := mongodb.Order.FindOne(c.Request().Context(), filter).Decode(&order); err != nil {
+		return err
 	}
+
 	wo := grocksdb.NewDefaultWriteOptions()
 	defer wo.Destroy()
+	batch := grocksdb.NewWriteBatch()
+	defer batch.Destroy()
 
 	orderKey, _ := base32.StdEncoding.DecodeString(order.Key)
-
-	mutex.Lock()
-	defer mutex.Unlock()
-	if err := book.Delete(wo, orderKey); err != nil {
+	balances := balanceUpdates{}
+	onBook, err := balances.releaseBookOrder(order.Symbol, order.Type, orderKey)
+	if err != nil {
+		return err
+	}
+	counts := openOrderUpdates{}
+	if onBook {
+		counts.leave(order.UserId)
+	}
+	if err := balances.write(batch); err != nil {
 		return err
 	}
+	rocksdb.Book(order.Symbol).Delete(batch, &order, orderKey)
+	if err := rocksdb.DB.Write(wo, batch); err != nil {
+		return err
+	}
+	counts.apply()
+	// The order is removed from the book, failing to store its status doesn't fail the request
+	ts := uint64(time.Now().UnixNano())
+	if _, err := mongodb.Order.UpdateOne(c.Request().Context(), bson.M{
+		"_id":    order.ID,
+		"status": bson.M{"$in": models.OpenStatuses},
+	}, transitionUpdate(bson.M{}, models.CANCELLED, ts)); err != nil {
+		log.Err(err).Interface("order", order).Msg("Store cancelled order")
+	}
+	order.SetStatus(models.CANCELLED, ts)
+	publishUserEvents(c.Request().Context(), []events.Event{orderStatusEvent(&order, ts)})
+	publishBookUpdate(c.Request().Context(), order.Symbol, []models.Order{order})
 
 	log.Info().Interface("order", order).Msg("Cancel order")
 	return c.String(http.StatusOK, order.ID.Hex())
@@ -0,0 +1,119 @@
+package trade
+
+import (
+	"encoding/base32"
+	"net/http"
+	"time"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/mongodb"
+	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/events"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/linxGnu/grocksdb"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type DeleteOrdersQuery struct {
+	Symbol string           `param:"symbol" query:"symbol" validate:"omitempty,valid_symbol"`
+	Type   models.OrderType `query:"type" validate:"omitempty,oneof=BUY SELL"`
+	// Inclusive price range
+	MinPrice models.Decimal `query:"minPrice" validate:"omitempty,price"`
+	MaxPrice models.Decimal `query:"maxPrice" validate:"omitempty,price"`
+}
+
+// CancelOrders cancels every open order of the user matching the filters, and returns their IDs
+func CancelOrders(c echo.Context) error {
+	req := DeleteOrdersQuery{}
+	if err := utils.BindNValidate(c, &req); err != nil {
+		return err
+	}
+	userId := c.Get("userId").(uint64)
+	reqCtx := c.Request().Context()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	ts := uint64(time.Now().UnixNano())
+	conditions := []bson.M{
+		{"user_id": userId},
+		openOrderFilter(ts),
+	}
+	if len(req.Symbol) > 0 {
+		conditions = append(conditions, bson.M{"symbol": req.Symbol})
+	}
+	if len(req.Type) > 0 {
+		conditions = append(conditions, bson.M{"type": req.Type})
+	}
+	if !req.MinPrice.IsZero() {
+		conditions = append(conditions, bson.M{"price": bson.M{"$gte": req.MinPrice}})
+	}
+	if !req.MaxPrice.IsZero() {
+		conditions = append(conditions, bson.M{"price": bson.M{"$lte": req.MaxPrice}})
+	}
+	orders := make([]models.Order, 0)
+	cursor, err := mongodb.Order.Find(reqCtx, bson.M{"$and": conditions})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(reqCtx)
+	if err := cursor.All(reqCtx, &orders); err != nil {
+		return err
+	}
+
+	orderIds := make([]primitive.ObjectID, len(orders))
+	if len(orders) == 0 {
+		return c.JSON(http.StatusOK, orderIds)
+	}
+
+	wo := grocksdb.NewDefaultWriteOptions()
+	defer wo.Destroy()
+	batch := grocksdb.NewWriteBatch()
+	defer batch.Destroy()
+	balances := balanceUpdates{}
+	counts := openOrderUpdates{}
+	for i := range orders {
+		orderKey, _ := base32.StdEncoding.DecodeString(orders[i].Key)
+		onBook, err := balances.releaseBookOrder(orders[i].Symbol, orders[i].Type, orderKey)
+		if err != nil {
+			return err
+		}
+		if onBook {
+			counts.leave(userId)
+		}
+		rocksdb.Book(orders[i].Symbol).Delete(batch, &orders[i], orderKey)
+		orderIds[i] = *orders[i].ID
+	}
+	if err := balances.write(batch); err != nil {
+		return err
+	}
+	if err := rocksdb.DB.Write(wo, batch); err != nil {
+		return err
+	}
+	counts.apply()
+
+	if _, err := mongodb.Order.UpdateMany(reqCtx, bson.M{
+		"_id": bson.M{"$in": orderIds},
+	}, transitionUpdate(bson.M{}, models.CANCELLED, ts)); err != nil {
+		// The orders are removed from the book, so the request doesn't fail
+		log.Err(err).Interface("orderIds", orderIds).Msg("Store cancelled orders")
+	}
+
+	changedLevels := map[string][]models.Order{}
+	cancelEvents := make([]events.Event, len(orders))
+	for i := range orders {
+		changedLevels[orders[i].Symbol] = append(changedLevels[orders[i].Symbol], orders[i])
+		orders[i].SetStatus(models.CANCELLED, ts)
+		cancelEvents[i] = orderStatusEvent(&orders[i], ts)
+	}
+	publishUserEvents(reqCtx, cancelEvents)
+	for symbol := range changedLevels {
+		publishBookUpdate(reqCtx, symbol, changedLevels[symbol])
+	}
+
+	log.Info().Uint64("userId", userId).Int("count", len(orderIds)).Msg("Cancel orders")
+	return c.JSON(http.StatusOK, orderIds)
+}
@@ -0,0 +1,116 @@
+package trade
+
+import (
+	"context"
+	"net/http"
+	"time"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/mongodb"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type GetCandlesticksQuery struct {
+	Symbol   string         `param:"symbol" query:"symbol" validate:"required,valid_symbol"`
+	Interval utils.Duration `query:"interval" validate:"required,candlestick_interval"`
+	// Unix timestamps in microseconds, -1 is the latest candlestick
+	From int64 `query:"from" validate:"required,micro_timestamp"`
+	To   int64 `query:"to" validate:"omitempty,micro_timestamp"`
+}
+
+const maxCandlesticks = 1000
+
+// recordCandles folds the fills of an order into the candlestick of each interval.
+// Fills of an order share its timestamp, so there is one candlestick to update by interval.
+func recordCandles(ctx context.Context, symbol string, fills []models.Trade) error {
+	if len(fills) == 0 {
+		return nil
+	}
+	open, high, low := fills[0].Price, fills[0].Price, fills[0].Price
+	volume, quoteVolume := models.Decimal{}, models.Decimal{}
+	for i := range fills {
+		high = models.MaxDecimal(high, fills[i].Price)
+		low = models.MinDecimal(low, fills[i].Price)
+		volume = volume.Add(fills[i].Quantity)
+		quoteVolume = quoteVolume.Add(fills[i].Price.Mul(fills[i].Quantity))
+	}
+	last := fills[len(fills)-1].Price
+	ts := time.Unix(0, int64(fills[0].Timestamp))
+
+	updates := make([]mongo.WriteModel, len(utils.CandlestickIntervals))
+	for i, interval := range utils.CandlestickIntervals {
+		updates[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{
+				"symbol":    symbol,
+				"interval":  interval,
+				"open_time": interval.Start(ts).UnixMicro(),
+			}).
+			SetUpdate(mongo.Pipeline{{{Key: "$set", Value: bson.M{
+				"open":         bson.M{"$ifNull": bson.A{"$open", open}},
+				"high":         bson.M{"$max": bson.A{"$high", high}},
+				"low":          bson.M{"$min": bson.A{"$low", low}},
+				"close":        last,
+				"volume":       bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$volume", 0}}, volume}},
+				"quote_volume": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$quote_volume", 0}}, quoteVolume}},
+				"count":        bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$count", 0}}, len(fills)}},
+			}}}}).
+			SetUpsert(true)
+	}
+	_, err := mongodb.Candle.BulkWrite(ctx, updates)
+	return err
+}
+
+// GetCandlesticks returns the candlesticks of a market between from & to, oldest first.
+// Intervals without trades have no candlestick.
+func GetCandlesticks(c echo.Context) error {
+	req := GetCandlesticksQuery{}
+	if err := utils.BindNValidate(c, &req); err != nil {
+		return err
+	}
+	reqCtx := c.Request().Context()
+	filter := bson.M{
+		"symbol":   req.Symbol,
+		"interval": req.Interval,
+	}
+
+	candles := make([]models.Candle, 0)
+	if req.From == -1 {
+		candle := models.Candle{}
+		err := mongodb.Candle.FindOne(reqCtx, filter,
+			options.FindOne().SetSort(bson.D{{Key: "open_time", Value: -1}}),
+		).Decode(&candle)
+		if err == mongo.ErrNoDocuments {
+			return c.JSON(http.StatusOK, candles)
+		}
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, append(candles, candle))
+	}
+
+	// The candlestick containing from is included
+	openTime := bson.M{"$gte": req.Interval.Start(time.UnixMicro(req.From)).UnixMicro()}
+	if req.To > 0 {
+		if req.To < req.From {
+			return echo.NewHTTPError(http.StatusBadRequest, "To must be greater than or equal to from")
+		}
+		openTime["$lte"] = req.To
+	}
+	filter["open_time"] = openTime
+	opts := options.Find().
+		SetSort(bson.D{{Key: "open_time", Value: 1}}).
+		SetLimit(maxCandlesticks)
+	cursor, err := mongodb.Candle.Find(reqCtx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(reqCtx)
+	if err := cursor.All(reqCtx, &candles); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, candles)
+}
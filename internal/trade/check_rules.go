@@ -0,0 +1,102 @@
+package trade
+
+import (
+	"context"
+	"fmt"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/market"
+	"trading-bsx/pkg/ratelimit"
+	"trading-bsx/pkg/utils"
+)
+
+func ruleViolation(field string, rule string, value models.Decimal, limit models.Decimal, msg string) *utils.ValidationError {
+	return &utils.ValidationError{
+		Message: msg,
+		Metadata: map[string]interface{}{
+			"field": field,
+			"rule":  rule,
+			"value": value.String(),
+			"limit": limit.String(),
+		},
+	}
+}
+
+// checkMarketRules returns the first trading rule of the market broken by the order, if any
+func checkMarketRules(ctx context.Context, order *models.Order) error {
+	rules := market.Get(order.Symbol).Rules
+	price, quantity := order.Price, order.Quantity
+
+	if err := checkQuantityRules(&rules, quantity); err != nil {
+		return err
+	}
+	// Market orders without slippage protection have no price to check
+	if price.IsZero() {
+		return nil
+	}
+
+	if !rules.TickSize.IsZero() && !price.Mod(rules.TickSize).IsZero() {
+		return ruleViolation("price", "tickSize", price, rules.TickSize,
+			fmt.Sprintf("Price must be a multiple of tick size %s", rules.TickSize))
+	}
+	if !rules.MinPrice.IsZero() && price.Cmp(rules.MinPrice) < 0 {
+		return ruleViolation("price", "minPrice", price, rules.MinPrice,
+			fmt.Sprintf("Price must be greater than or equal to %s", rules.MinPrice))
+	}
+	if !rules.MaxPrice.IsZero() && price.Cmp(rules.MaxPrice) > 0 {
+		return ruleViolation("price", "maxPrice", price, rules.MaxPrice,
+			fmt.Sprintf("Price must be less than or equal to %s", rules.MaxPrice))
+	}
+	if !rules.PriceBand.IsZero() {
+		lastPrice, err := lastTradePrice(ctx, order.Symbol)
+		if err != nil {
+			return err
+		}
+		// No band until the market has traded
+		if lastPrice != nil && price.Sub(*lastPrice).Abs().Cmp(lastPrice.Mul(rules.PriceBand)) > 0 {
+			return ruleViolation("price", "priceBand", price, rules.PriceBand,
+				fmt.Sprintf("Price must be within %s%% of the last trade price %s", rules.PriceBand.Mul(models.NewDecimalFromInt(100)), lastPrice))
+		}
+	}
+
+	notional := price.Mul(quantity)
+	if !rules.MinNotional.IsZero() && notional.Cmp(rules.MinNotional) < 0 {
+		return ruleViolation("notional", "minNotional", notional, rules.MinNotional,
+			fmt.Sprintf("Order value must be greater than or equal to %s", rules.MinNotional))
+	}
+	return nil
+}
+
+func checkQuantityRules(rules *market.Rules, quantity models.Decimal) error {
+	if !rules.MinQuantity.IsZero() && quantity.Cmp(rules.MinQuantity) < 0 {
+		return ruleViolation("quantity", "minQuantity", quantity, rules.MinQuantity,
+			fmt.Sprintf("Quantity must be greater than or equal to %s", rules.MinQuantity))
+	}
+	if !rules.MaxQuantity.IsZero() && quantity.Cmp(rules.MaxQuantity) > 0 {
+		return ruleViolation("quantity", "maxQuantity", quantity, rules.MaxQuantity,
+			fmt.Sprintf("Quantity must be less than or equal to %s", rules.MaxQuantity))
+	}
+	if !rules.LotSize.IsZero() && !quantity.Mod(rules.LotSize).IsZero() {
+		return ruleViolation("quantity", "lotSize", quantity, rules.LotSize,
+			fmt.Sprintf("Quantity must be a multiple of lot size %s", rules.LotSize))
+	}
+	return nil
+}
+
+// checkOpenOrders rejects an order which could rest on the book when the user has too many open orders.
+// The matching mutex must be locked, so that concurrent orders are counted.
+func checkOpenOrders(order *models.Order) error {
+	maxOpenOrders := ratelimit.Get().MaxOpenOrders
+	if maxOpenOrders == 0 || order.TimeInForce == models.IOC || order.TimeInForce == models.FOK {
+		return nil
+	}
+	if openOrderCount(order.UserId) >= maxOpenOrders {
+		return &utils.ValidationError{
+			Message: fmt.Sprintf("A user can't have more than %d open orders", maxOpenOrders),
+			Metadata: map[string]interface{}{
+				"rule":  "maxOpenOrders",
+				"limit": maxOpenOrders,
+			},
+		}
+	}
+	return nil
+}
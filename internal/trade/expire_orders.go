@@ -0,0 +1,171 @@
+package trade
+
+import (
+	"context"
+	"encoding/base32"
+	"time"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/mongodb"
+	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/events"
+	"trading-bsx/pkg/market"
+
+	"github.com/linxGnu/grocksdb"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Max time between 2 runs of the expiry reaper, when no order is about to expire
+const maxReapInterval = time.Minute
+
+var reaperWake = make(chan struct{}, 1)
+
+// wakeExpiryReaper makes the reaper reschedule, after an order with an expiration time rests on the book
+func wakeExpiryReaper() {
+	select {
+	case reaperWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartExpiryReaper removes orders from the books as soon as they expire, marks them EXPIRED and publishes an expiry event.
+// It returns a function stopping the reaper.
+func StartExpiryReaper() func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-reaperWake:
+			case <-timer.C:
+			}
+
+			next, err := reapExpiredOrders(context.Background())
+			if err != nil {
+				log.Err(err).Msg("Reap expired orders")
+				next = time.Now().Add(time.Second)
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(time.Until(next))
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// reapExpiredOrders removes the expired orders of every market & returns when the next order expires
+func reapExpiredOrders(ctx context.Context) (time.Time, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	now := uint64(time.Now().UnixNano())
+	next := now + uint64(maxReapInterval)
+	for _, symbol := range market.Symbols() {
+		nextExpiry, err := reapMarket(ctx, symbol, now)
+		if err != nil {
+			return time.Now(), err
+		}
+		if nextExpiry > 0 && nextExpiry < next {
+			next = nextExpiry
+		}
+	}
+	// Orders are expired once the time passes their expiration time
+	return time.Unix(0, int64(next+1)), nil
+}
+
+// reapMarket removes the orders of a market expired at ts.
+// It returns the expiration time of the next order to expire, or 0 if there is none.
+func reapMarket(ctx context.Context, symbol string, ts uint64) (uint64, error) {
+	orderBook := rocksdb.Book(symbol)
+
+	ro := grocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+	it := rocksdb.DB.NewIteratorCF(ro, orderBook.Expiry)
+	defer it.Close()
+
+	wo := grocksdb.NewDefaultWriteOptions()
+	defer wo.Destroy()
+	batch := grocksdb.NewWriteBatch()
+	defer batch.Destroy()
+
+	var nextExpiry uint64
+	balances := balanceUpdates{}
+	counts := openOrderUpdates{}
+	expiredKeys := make([]string, 0)
+	changedLevels := make([]models.Order, 0)
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		// Iterator memory is reused after moving, so the key must be copied
+		k := append([]byte{}, it.Key().Data()...)
+		expiredAt, side, orderKey := rocksdb.ParseExpiryKey(k)
+		if expiredAt >= ts {
+			nextExpiry = expiredAt
+			break
+		}
+		onBook, err := balances.releaseBookOrder(symbol, side, orderKey)
+		if err != nil {
+			return 0, err
+		}
+		if onBook {
+			order := models.Order{Type: side}
+			order.ParseKV(orderKey, nil)
+			counts.leave(order.UserId)
+		}
+		batch.DeleteCF(orderBook.Side(side), orderKey)
+		batch.DeleteCF(orderBook.Expiry, k)
+		expiredKeys = append(expiredKeys, base32.StdEncoding.EncodeToString(orderKey))
+		changedLevels = append(changedLevels, models.Order{Type: side, Price: models.DecimalFromBytes(orderKey[:16])})
+	}
+	if len(expiredKeys) == 0 {
+		return nextExpiry, nil
+	}
+	if err := balances.write(batch); err != nil {
+		return 0, err
+	}
+	if err := rocksdb.DB.Write(wo, batch); err != nil {
+		return 0, err
+	}
+	counts.apply()
+
+	markExpired(ctx, symbol, expiredKeys, ts)
+	publishBookUpdate(ctx, symbol, changedLevels)
+	return nextExpiry, nil
+}
+
+// markExpired marks the orders removed from the book as EXPIRED, and publishes their expiry events.
+// The orders are already removed from the book, so failures are logged.
+func markExpired(ctx context.Context, symbol string, keys []string, ts uint64) {
+	expiryEvents := make([]events.Event, 0, len(keys))
+	for _, key := range keys {
+		order := models.Order{}
+		err := mongodb.Order.FindOneAndUpdate(ctx, bson.M{
+			"symbol": symbol,
+			"key":    key,
+			"status": bson.M{"$in": models.OpenStatuses},
+		}, transitionUpdate(bson.M{}, models.EXPIRED, ts)).Decode(&order)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			log.Err(err).Str("symbol", symbol).Str("key", key).Msg("Store expired order")
+			continue
+		}
+		// The update returns the document before the transition
+		order.SetStatus(models.EXPIRED, ts)
+		log.Info().Interface("order", order).Msg("Expire order")
+		expiryEvents = append(expiryEvents, orderStatusEvent(&order, ts))
+	}
+	publishUserEvents(ctx, expiryEvents)
+}
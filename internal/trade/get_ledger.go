@@ -0,0 +1,34 @@
+package trade
+
+import (
+	"net/http"
+	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+type GetLedgerQuery struct {
+	Asset string `query:"asset" validate:"omitempty,valid_wallet_type"`
+	Limit int    `query:"limit" validate:"omitempty,gt=0,lte=1000"`
+	// Sequence of the last entry of the previous page
+	After uint64 `query:"after"`
+}
+
+const defaultLedgerLimit = 100
+
+// GetLedger returns the statement of the user: the journal entries of its funds, latest first
+func GetLedger(c echo.Context) error {
+	req := GetLedgerQuery{}
+	if err := utils.BindNValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultLedgerLimit
+	}
+	entries, err := rocksdb.UserLedgerEntries(c.Get("userId").(uint64), req.Asset, req.After, req.Limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,21 @@
+package trade
+
+import (
+	"net/http"
+	"trading-bsx/pkg/market"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+)
+
+type MarketParam struct {
+	Symbol string `param:"symbol" validate:"required,valid_symbol"`
+}
+
+func GetMarketRules(c echo.Context) error {
+	req := MarketParam{}
+	if err := utils.BindNValidate(c, &req); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, market.Get(req.Symbol).Rules)
+}
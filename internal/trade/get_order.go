@@ -0,0 +1,76 @@
+package trade
+
+import (
+	"net/http"
+	"time"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/mongodb"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type GetOrderParam struct {
+	Symbol        string             `param:"symbol" validate:"omitempty,valid_symbol"`
+	OrderId       primitive.ObjectID `param:"order_id" validate:"required_without=ClientOrderId"`
+	ClientOrderId string             `param:"client_order_id" validate:"omitempty,client_order_id"`
+}
+
+// OrderDetail is an order with its fills, oldest first
+type OrderDetail struct {
+	models.Order `bson:",inline"`
+	Filled       models.Decimal `json:"filled"`
+	Fills        []models.Trade `json:"fills"`
+}
+
+// GetOrder returns an order of the user by its ID or its client order ID, whatever its status
+func GetOrder(c echo.Context) error {
+	req := GetOrderParam{}
+	if err := utils.BindNValidate(c, &req); err != nil {
+		return err
+	}
+
+	filter := bson.M{"user_id": c.Get("userId").(uint64)}
+	if len(req.ClientOrderId) > 0 {
+		filter["client_order_id"] = req.ClientOrderId
+	} else {
+		filter["_id"] = req.OrderId
+	}
+	if len(req.Symbol) > 0 {
+		filter["symbol"] = req.Symbol
+	}
+	reqCtx := c.Request().Context()
+	order := models.Order{}
+	if err := mongodb.Order.FindOne(reqCtx, filter).Decode(&order); err != nil {
+		return err
+	}
+	// Expired orders are marked lazily, report them as such
+	if order.IsOpen() && order.IsExpired(uint64(time.Now().UnixNano())) {
+		order.SetStatus(models.EXPIRED, *order.ExpiredAt)
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}})
+	cursor, err := mongodb.Trade.Find(reqCtx, bson.M{
+		"symbol": order.Symbol,
+		"$or": []bson.M{
+			{"maker_order_id": order.ID},
+			{"taker_order_id": order.ID},
+		},
+	}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(reqCtx)
+	detail := OrderDetail{
+		Order:  order,
+		Filled: order.Quantity.Sub(order.Remaining),
+		Fills:  make([]models.Trade, 0),
+	}
+	if err := cursor.All(reqCtx, &detail.Fills); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, detail)
+}
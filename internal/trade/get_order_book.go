@@ -0,0 +1,116 @@
+package trade
+
+import (
+	"net/http"
+	"time"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/linxGnu/grocksdb"
+)
+
+type GetOrderBookQuery struct {
+	Symbol string `param:"symbol" query:"symbol" validate:"required,valid_symbol"`
+	// Number of price levels of each side
+	Depth int `query:"depth" validate:"omitempty,gt=0,lte=1000"`
+	// 2 aggregates orders by price level, 3 also lists the orders of each level
+	Level int `query:"level" validate:"omitempty,oneof=2 3"`
+}
+
+const defaultBookDepth = 50
+
+// PriceLevel is the resting quantity at a price
+type PriceLevel struct {
+	Price    models.Decimal `json:"price"`
+	Quantity models.Decimal `json:"quantity"`
+	Count    int            `json:"count"`
+	// Orders of the level in priority order, without their owner, in level 3 only
+	Orders []BookEntry `json:"orders,omitempty"`
+}
+
+type BookEntry struct {
+	Quantity  models.Decimal `json:"quantity"`
+	Timestamp uint64         `json:"timestamp"`
+}
+
+type OrderBookDepth struct {
+	Symbol    string       `json:"symbol"`
+	Bids      []PriceLevel `json:"bids"`
+	Asks      []PriceLevel `json:"asks"`
+	Timestamp uint64       `json:"timestamp"`
+}
+
+// GetOrderBook returns the best price levels of both sides of the book, best first
+func GetOrderBook(c echo.Context) error {
+	req := GetOrderBookQuery{}
+	if err := utils.BindNValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Depth == 0 {
+		req.Depth = defaultBookDepth
+	}
+
+	// Both sides are read from the same snapshot
+	snapshot := rocksdb.DB.NewSnapshot()
+	defer rocksdb.DB.ReleaseSnapshot(snapshot)
+	return c.JSON(http.StatusOK, readOrderBook(snapshot, &req))
+}
+
+func readOrderBook(snapshot *grocksdb.Snapshot, req *GetOrderBookQuery) OrderBookDepth {
+	ro := grocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+	ro.SetSnapshot(snapshot)
+
+	orderBook := rocksdb.Book(req.Symbol)
+	result := OrderBookDepth{
+		Symbol:    req.Symbol,
+		Timestamp: uint64(time.Now().UnixNano()),
+	}
+	result.Bids = readBookLevels(ro, orderBook.BuyOrder, &models.Order{Symbol: req.Symbol, Type: models.BUY}, req, result.Timestamp)
+	result.Asks = readBookLevels(ro, orderBook.SellOrder, &models.Order{Symbol: req.Symbol, Type: models.SELL}, req, result.Timestamp)
+	return result
+}
+
+// readBookLevels aggregates one side of the book, from the best price.
+// Expired orders are skipped, the matching engine or the reaper removes them.
+func readBookLevels(ro *grocksdb.ReadOptions, cf *grocksdb.ColumnFamilyHandle, side *models.Order, req *GetOrderBookQuery, ts uint64) []PriceLevel {
+	it := rocksdb.DB.NewIteratorCF(ro, cf)
+	defer it.Close()
+
+	// Buy orders are sorted by ascending price, the best one is the last
+	next := it.Next
+	if side.Type == models.BUY {
+		it.SeekToLast()
+		next = it.Prev
+	} else {
+		it.SeekToFirst()
+	}
+
+	levels := make([]PriceLevel, 0)
+	for ; it.Valid(); next() {
+		order := *side
+		order.ParseKV(it.Key().Data(), it.Value().Data())
+		if order.IsExpired(ts) {
+			continue
+		}
+		last := len(levels) - 1
+		if last < 0 || !levels[last].Price.Equal(order.Price) {
+			if len(levels) == req.Depth {
+				break
+			}
+			levels = append(levels, PriceLevel{Price: order.Price})
+			last++
+		}
+		levels[last].Quantity = levels[last].Quantity.Add(order.Remaining)
+		levels[last].Count++
+		if req.Level == 3 {
+			levels[last].Orders = append(levels[last].Orders, BookEntry{
+				Quantity:  order.Remaining,
+				Timestamp: order.Timestamp,
+			})
+		}
+	}
+	return levels
+}
@@ -5,29 +5,115 @@ import (
 	"time"
 	"trading-bsx/pkg/db/models"
 	"trading-bsx/pkg/db/mongodb"
+	"trading-bsx/pkg/utils"
 
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+type GetOrdersQuery struct {
+	Symbol string             `param:"symbol" query:"symbol" validate:"omitempty,valid_symbol"`
+	Status models.OrderStatus `query:"status" validate:"omitempty,oneof=NEW PARTIALLY_FILLED FILLED CANCELLED EXPIRED REJECTED"`
+	Type   models.OrderType   `query:"type" validate:"omitempty,oneof=BUY SELL"`
+	// Inclusive price range
+	MinPrice models.Decimal `query:"minPrice" validate:"omitempty,price"`
+	MaxPrice models.Decimal `query:"maxPrice" validate:"omitempty,price"`
+	// Creation time range, as Unix timestamps in milliseconds: from is inclusive, to is exclusive.
+	// Bounded to fit in nanoseconds.
+	From uint64 `query:"from" validate:"omitempty,lte=18446744073709"`
+	To   uint64 `query:"to" validate:"omitempty,lte=18446744073709,gtfield=From"`
+	// Sort field & direction, latest orders first by default
+	Sort  string `query:"sort" validate:"omitempty,oneof=createdAt price"`
+	Order string `query:"order" validate:"omitempty,oneof=asc desc"`
+	Limit int64  `query:"limit" validate:"omitempty,gt=0,lte=1000"`
+	// ID of the last order of the previous page
+	After primitive.ObjectID `query:"after"`
+}
+
+const defaultOrdersLimit = 100
+
+var orderSortFields = map[string]string{
+	"":          "created_at",
+	"createdAt": "created_at",
+	"price":     "price",
+}
+
+// GetOrders returns a page of the user's orders, open orders by default
 func GetOrders(c echo.Context) error {
+	req := GetOrdersQuery{}
+	if err := utils.BindNValidate(c, &req); err != nil {
+		return err
+	}
 	userId := c.Get("userId").(uint64)
 	reqCtx := c.Request().Context()
 	orders := make([]models.Order, 0)
 	ts := uint64(time.Now().UnixNano())
-	filter := bson.M{
-		"$and": []bson.M{
-			{"user_id": userId},
-			{
-				"$or": []bson.M{
-					{"expired_at": bson.M{"$gte": ts}},
-					{"expired_at": 0},
-					{"expired_at": bson.M{"$exists": false}},
+	conditions := []bson.M{
+		{"user_id": userId},
+	}
+	switch req.Status {
+	case "":
+		// Open orders by default
+		conditions = append(conditions, openOrderFilter(ts))
+	case models.NEW, models.PARTIALLY_FILLED:
+		conditions = append(conditions, openOrderFilter(ts), bson.M{"status": req.Status})
+	case models.EXPIRED:
+		// Expired orders are marked lazily, once the matching engine meets them
+		conditions = append(conditions, bson.M{
+			"$or": []bson.M{
+				{"status": models.EXPIRED},
+				{
+					"status":     bson.M{"$in": models.OpenStatuses},
+					"expired_at": bson.M{"$gt": 0, "$lt": ts},
 				},
 			},
-		},
+		})
+	default:
+		conditions = append(conditions, bson.M{"status": req.Status})
+	}
+	if len(req.Symbol) > 0 {
+		conditions = append(conditions, bson.M{"symbol": req.Symbol})
+	}
+	if len(req.Type) > 0 {
+		conditions = append(conditions, bson.M{"type": req.Type})
+	}
+	if !req.MinPrice.IsZero() {
+		conditions = append(conditions, bson.M{"price": bson.M{"$gte": req.MinPrice}})
+	}
+	if !req.MaxPrice.IsZero() {
+		conditions = append(conditions, bson.M{"price": bson.M{"$lte": req.MaxPrice}})
+	}
+	if req.From > 0 {
+		conditions = append(conditions, bson.M{"created_at": bson.M{"$gte": req.From * uint64(time.Millisecond)}})
 	}
-	cursor, err := mongodb.Order.Find(reqCtx, filter)
+	if req.To > 0 {
+		conditions = append(conditions, bson.M{"created_at": bson.M{"$lt": req.To * uint64(time.Millisecond)}})
+	}
+
+	sortField := orderSortFields[req.Sort]
+	direction, op := -1, "$lt"
+	if req.Order == "asc" {
+		direction, op = 1, "$gt"
+	}
+	if !req.After.IsZero() {
+		cursorCondition, err := orderCursorFilter(c, userId, req.After, sortField, op)
+		if err != nil {
+			return err
+		}
+		conditions = append(conditions, cursorCondition)
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultOrdersLimit
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: sortField, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(limit)
+	cursor, err := mongodb.Order.Find(reqCtx, bson.M{"$and": conditions}, opts)
 	if err != nil {
 		return err
 	}
@@ -36,6 +122,34 @@ func GetOrders(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	for i := range orders {
+		// Expired orders are marked lazily, report them as such
+		if orders[i].IsOpen() && orders[i].IsExpired(ts) {
+			orders[i].SetStatus(models.EXPIRED, *orders[i].ExpiredAt)
+		}
+	}
 
 	return c.JSON(http.StatusOK, orders)
 }
+
+// orderCursorFilter matches the orders sorted after the cursor order, ties being broken by ID
+func orderCursorFilter(c echo.Context, userId uint64, after primitive.ObjectID, sortField string, op string) (bson.M, error) {
+	last := bson.M{}
+	err := mongodb.Order.FindOne(c.Request().Context(), bson.M{
+		"_id":     after,
+		"user_id": userId,
+	}, options.FindOne().SetProjection(bson.M{sortField: 1})).Decode(&last)
+	if err == mongo.ErrNoDocuments {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid after cursor")
+	}
+	if err != nil {
+		return nil, err
+	}
+	value := last[sortField]
+	return bson.M{
+		"$or": []bson.M{
+			{sortField: bson.M{op: value}},
+			{sortField: value, "_id": bson.M{op: after}},
+		},
+	}, nil
+}
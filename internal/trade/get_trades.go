@@ -0,0 +1,78 @@
+package trade
+
+import (
+	"net/http"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/mongodb"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type GetTradesQuery struct {
+	Symbol string `query:"symbol" validate:"omitempty,valid_symbol"`
+	Limit  int64  `query:"limit" validate:"omitempty,gt=0,lte=1000"`
+}
+
+const defaultTradesLimit = 100
+
+// GetTrades returns the fills of the user, latest first
+func GetTrades(c echo.Context) error {
+	req := GetTradesQuery{}
+	if err := utils.BindNValidate(c, &req); err != nil {
+		return err
+	}
+	userId := c.Get("userId").(uint64)
+
+	filter := bson.M{
+		"$or": []bson.M{
+			{"maker_user_id": userId},
+			{"taker_user_id": userId},
+		},
+	}
+	if len(req.Symbol) > 0 {
+		filter["symbol"] = req.Symbol
+	}
+	return findTrades(c, filter, req.Limit)
+}
+
+// GetMarketTrades returns the recent public trades, latest first
+func GetMarketTrades(c echo.Context) error {
+	req := GetTradesQuery{}
+	if err := utils.BindNValidate(c, &req); err != nil {
+		return err
+	}
+
+	filter := bson.M{}
+	if len(req.Symbol) > 0 {
+		filter["symbol"] = req.Symbol
+	}
+	return findTrades(c, filter, req.Limit)
+}
+
+func findTrades(c echo.Context, filter bson.M, limit int64) error {
+	if limit == 0 {
+		limit = defaultTradesLimit
+	}
+	reqCtx := c.Request().Context()
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "sequence", Value: -1}}).
+		SetLimit(limit)
+	cursor, err := mongodb.Trade.Find(reqCtx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(reqCtx)
+
+	trades := make([]models.Trade, 0)
+	if err := cursor.All(reqCtx, &trades); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, trades)
+}
+
+func tradeSequenceName(symbol string) string {
+	return "trade/" + symbol
+}
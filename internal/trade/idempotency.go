@@ -0,0 +1,97 @@
+package trade
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+	"trading-bsx/pkg/db/mongodb"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const IDEMPOTENCY_KEY_HEADER = "Idempotency-Key"
+
+// Set on responses replaying the result of a previous request
+const IDEMPOTENT_REPLAYED_HEADER = "Idempotent-Replayed"
+
+// placedOrder is the result of a placed order, stored to answer retries of its request.
+// A retry is recognized by the idempotency key or the client order ID of the request.
+type placedOrder struct {
+	UserId         uint64           `bson:"user_id"`
+	IdempotencyKey string           `bson:"idempotency_key,omitempty"`
+	ClientOrderId  string           `bson:"client_order_id,omitempty"`
+	RequestHash    string           `bson:"request_hash"`
+	Result         PlaceOrderResult `bson:"result"`
+	CreatedAt      time.Time        `bson:"created_at"`
+}
+
+func hashRequest(body *CreateOrder) string {
+	data, _ := json.Marshal(body)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// findPlacedOrder returns the result of a previous request with the same idempotency key or client order ID, if any.
+// It fails if that request was different, or if the client order ID is taken by an order whose result is gone.
+func findPlacedOrder(ctx context.Context, userId uint64, idempotencyKey string, body *CreateOrder) (*PlaceOrderResult, error) {
+	conditions := make([]bson.M, 0, 2)
+	if len(idempotencyKey) > 0 {
+		conditions = append(conditions, bson.M{"idempotency_key": idempotencyKey})
+	}
+	if len(body.ClientOrderId) > 0 {
+		conditions = append(conditions, bson.M{"client_order_id": body.ClientOrderId})
+	}
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+
+	placed := placedOrder{}
+	err := mongodb.PlacedOrder.FindOne(ctx, bson.M{
+		"user_id": userId,
+		"$or":     conditions,
+	}).Decode(&placed)
+	if err == mongo.ErrNoDocuments {
+		if len(body.ClientOrderId) == 0 {
+			return nil, nil
+		}
+		count, err := mongodb.Order.CountDocuments(ctx, bson.M{
+			"user_id":         userId,
+			"client_order_id": body.ClientOrderId,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, echo.NewHTTPError(http.StatusConflict, "Client order ID is already used")
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if placed.RequestHash != hashRequest(body) {
+		return nil, echo.NewHTTPError(http.StatusConflict, "Idempotency key or client order ID is already used by a different request")
+	}
+	return &placed.Result, nil
+}
+
+// storePlacedOrder keeps the result of a request with an idempotency key or a client order ID
+func storePlacedOrder(ctx context.Context, idempotencyKey string, body *CreateOrder, result *PlaceOrderResult) error {
+	if len(idempotencyKey) == 0 && len(body.ClientOrderId) == 0 {
+		return nil
+	}
+	_, err := mongodb.PlacedOrder.InsertOne(ctx, placedOrder{
+		UserId:         result.Order.UserId,
+		IdempotencyKey: idempotencyKey,
+		ClientOrderId:  body.ClientOrderId,
+		RequestHash:    hashRequest(body),
+		Result:         *result,
+		CreatedAt:      time.Now(),
+	})
+	return err
+}
@@ -0,0 +1,203 @@
+package trade
+
+import (
+	"bytes"
+	"context"
+	"time"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/mongodb"
+	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/events"
+	"trading-bsx/pkg/utils"
+
+	"github.com/linxGnu/grocksdb"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// BookUpdate is the new state of the price levels changed in a book. Removed levels have a zero quantity.
+type BookUpdate struct {
+	Bids []PriceLevel `json:"bids"`
+	Asks []PriceLevel `json:"asks"`
+}
+
+// Market data channels, whose events are numbered separately
+const (
+	BOOK_CHANNEL    = "book"
+	TICKER_CHANNEL  = "ticker"
+	CANDLES_CHANNEL = "candles"
+	TRADES_CHANNEL  = "trades"
+)
+
+// Last sequence of the events of each market & channel, and of each user, loaded from RocksDB on first use.
+// Events are published under the matching mutex, which guards it.
+var eventSequences = map[string]uint64{}
+
+// candleChannel numbers the candlesticks of each interval separately
+func candleChannel(interval string) string {
+	return CANDLES_CHANNEL + "/" + interval
+}
+
+func eventSequenceName(symbol string, channel string) string {
+	return "events/" + symbol + "/" + channel
+}
+
+// lastEventSequence returns the sequence of the last event published in the channel of the market.
+// The matching mutex must be locked.
+func lastEventSequence(symbol string, channel string) (uint64, error) {
+	return cachedSequence(eventSequenceName(symbol, channel))
+}
+
+// cachedSequence returns the last value of a sequence of events. The matching mutex must be locked.
+func cachedSequence(name string) (uint64, error) {
+	if seq, ok := eventSequences[name]; ok {
+		return seq, nil
+	}
+	seq, err := rocksdb.LastSequence(name)
+	if err != nil {
+		return 0, err
+	}
+	eventSequences[name] = seq
+	return seq, nil
+}
+
+// publishMarketEvent publishes an event with the next sequence of its market & channel.
+// The matching mutex must be locked.
+func publishMarketEvent(channel string, event events.Event) error {
+	seq, err := lastEventSequence(event.Symbol, channel)
+	if err != nil {
+		return err
+	}
+	seq++
+	name := eventSequenceName(event.Symbol, channel)
+
+	wo := grocksdb.NewDefaultWriteOptions()
+	defer wo.Destroy()
+	batch := grocksdb.NewWriteBatch()
+	defer batch.Destroy()
+	rocksdb.SetSequence(batch, name, seq)
+	if err := rocksdb.DB.Write(wo, batch); err != nil {
+		return err
+	}
+	eventSequences[name] = seq
+
+	event.Sequence = seq
+	events.Publish(event)
+	return nil
+}
+
+// levelQuantity sums the orders resting at a price, expired orders excluded
+func levelQuantity(cf *grocksdb.ColumnFamilyHandle, side models.OrderType, price models.Decimal, ts uint64) PriceLevel {
+	level := PriceLevel{Price: price}
+	prefix, err := price.Bytes16()
+	if err != nil {
+		return level
+	}
+	ro := grocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+	it := rocksdb.DB.NewIteratorCF(ro, cf)
+	defer it.Close()
+	for it.Seek(prefix); it.Valid() && bytes.HasPrefix(it.Key().Data(), prefix); it.Next() {
+		order := models.Order{Type: side}
+		order.ParseKV(it.Key().Data(), it.Value().Data())
+		if order.IsExpired(ts) {
+			continue
+		}
+		level.Quantity = level.Quantity.Add(order.Remaining)
+		level.Count++
+	}
+	return level
+}
+
+// publishBookUpdate publishes the new state of the price levels of the orders, after a write to the book of the market.
+// Only the side & the price of the orders are used. The matching mutex must be locked.
+func publishBookUpdate(ctx context.Context, symbol string, orders []models.Order) {
+	if len(orders) == 0 {
+		return
+	}
+	ts := uint64(time.Now().UnixNano())
+	orderBook := rocksdb.Book(symbol)
+	update := BookUpdate{
+		Bids: make([]PriceLevel, 0),
+		Asks: make([]PriceLevel, 0),
+	}
+	seen := map[string]bool{}
+	for i := range orders {
+		key := string(orders[i].Type) + "/" + orders[i].Price.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		level := levelQuantity(orderBook.Side(orders[i].Type), orders[i].Type, orders[i].Price, ts)
+		if orders[i].Type == models.BUY {
+			update.Bids = append(update.Bids, level)
+		} else {
+			update.Asks = append(update.Asks, level)
+		}
+	}
+
+	// Events are published once the state is stored, failing to publish them doesn't fail the request
+	if err := publishMarketEvent(BOOK_CHANNEL, events.Event{
+		Type:      events.BOOK_UPDATE,
+		Symbol:    symbol,
+		Data:      update,
+		Timestamp: ts,
+	}); err != nil {
+		log.Err(err).Str("symbol", symbol).Msg("Publish book update")
+		return
+	}
+	ticker, err := marketTicker(ctx, symbol)
+	if err == nil {
+		err = publishMarketEvent(TICKER_CHANNEL, events.Event{
+			Type:      events.TICKER,
+			Symbol:    symbol,
+			Data:      ticker,
+			Timestamp: ticker.Timestamp,
+		})
+	}
+	if err != nil {
+		log.Err(err).Str("symbol", symbol).Msg("Publish ticker")
+	}
+}
+
+// publishTrades publishes the trades of a market, whose sequence is the trade sequence
+func publishTrades(symbol string, trades []models.Trade) {
+	for i := range trades {
+		events.Publish(events.Event{
+			Type:      events.TRADE,
+			Symbol:    symbol,
+			Sequence:  trades[i].Sequence,
+			Data:      trades[i],
+			Timestamp: trades[i].Timestamp,
+		})
+	}
+}
+
+// publishCandles publishes the candlesticks of every interval containing ts, once they are updated by trades.
+// The matching mutex must be locked.
+func publishCandles(ctx context.Context, symbol string, ts uint64) {
+	conditions := make([]bson.M, len(utils.CandlestickIntervals))
+	for i, interval := range utils.CandlestickIntervals {
+		conditions[i] = bson.M{
+			"interval":  interval,
+			"open_time": interval.Start(time.Unix(0, int64(ts))).UnixMicro(),
+		}
+	}
+	candles := make([]models.Candle, 0)
+	cursor, err := mongodb.Candle.Find(ctx, bson.M{"symbol": symbol, "$or": conditions})
+	if err == nil {
+		defer cursor.Close(ctx)
+		err = cursor.All(ctx, &candles)
+	}
+	for i := 0; i < len(candles) && err == nil; i++ {
+		err = publishMarketEvent(candleChannel(candles[i].Interval), events.Event{
+			Type:      events.CANDLE,
+			Symbol:    symbol,
+			Data:      candles[i],
+			Timestamp: ts,
+		})
+	}
+	if err != nil {
+		log.Err(err).Str("symbol", symbol).Msg("Publish candlesticks")
+	}
+}
@@ -0,0 +1,184 @@
+package trade
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/events"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+)
+
+// Messages of the stream which are not published events
+const (
+	SUBSCRIBED    events.EventType = "SUBSCRIBED"
+	UNSUBSCRIBED  events.EventType = "UNSUBSCRIBED"
+	BOOK_SNAPSHOT events.EventType = "BOOK_SNAPSHOT"
+	STREAM_ERROR  events.EventType = "ERROR"
+)
+
+// Events buffered for a stream client, later events are dropped until it catches up
+const streamBuffer = 1024
+
+type StreamRequest struct {
+	Op      string `json:"op" validate:"required,oneof=subscribe unsubscribe"`
+	Channel string `json:"channel" validate:"required,oneof=trades book ticker candles"`
+	Symbol  string `json:"symbol" validate:"required,valid_symbol"`
+	// Interval of the candles channel
+	Interval utils.Duration `json:"interval" validate:"required_if=Channel candles,omitempty,candlestick_interval"`
+	// Number of price levels of each side in the book snapshot
+	Depth int `json:"depth" validate:"omitempty,gt=0,lte=1000"`
+}
+
+// Subscribed is the state of a channel when it is subscribed. Events of the channel follow sequence.
+type Subscribed struct {
+	Channel  string         `json:"channel"`
+	Interval utils.Duration `json:"interval,omitempty"`
+}
+
+func (req *StreamRequest) channel() string {
+	if req.Channel == CANDLES_CHANNEL {
+		return candleChannel(string(req.Interval))
+	}
+	return req.Channel
+}
+
+// eventChannel returns the market data channel of an event, or "" for private events
+func eventChannel(event *events.Event) string {
+	switch event.Type {
+	case events.TRADE:
+		return TRADES_CHANNEL
+	case events.BOOK_UPDATE:
+		return BOOK_CHANNEL
+	case events.TICKER:
+		return TICKER_CHANNEL
+	case events.CANDLE:
+		if candle, ok := event.Data.(models.Candle); ok {
+			return candleChannel(candle.Interval)
+		}
+	}
+	return ""
+}
+
+// channelSequence returns the sequence of the last event of a channel, with a book snapshot for the book channel.
+// The state is read under the matching mutex, so that it is consistent with the sequence.
+func channelSequence(req *StreamRequest) (uint64, *OrderBookDepth, error) {
+	mutex.Lock()
+	var seq uint64
+	var err error
+	if req.Channel == TRADES_CHANNEL {
+		seq, err = rocksdb.LastSequence(tradeSequenceName(req.Symbol))
+	} else {
+		seq, err = lastEventSequence(req.Symbol, req.channel())
+	}
+	if err != nil || req.Channel != BOOK_CHANNEL {
+		mutex.Unlock()
+		return seq, nil, err
+	}
+	snapshot := rocksdb.DB.NewSnapshot()
+	mutex.Unlock()
+	defer rocksdb.DB.ReleaseSnapshot(snapshot)
+
+	query := GetOrderBookQuery{Symbol: req.Symbol, Depth: req.Depth}
+	if query.Depth == 0 {
+		query.Depth = defaultBookDepth
+	}
+	book := readOrderBook(snapshot, &query)
+	return seq, &book, nil
+}
+
+// MarketStream is a WebSocket streaming the market data channels subscribed by the client.
+// Each channel of a market has its own sequence: a client missing a sequence must subscribe again to resync.
+func MarketStream(c echo.Context) error {
+	websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		eventCh, unsubscribe := events.Subscribe(streamBuffer)
+		defer unsubscribe()
+
+		requests := make(chan string)
+		done := make(chan struct{})
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			defer close(done)
+			for {
+				msg := ""
+				if err := websocket.Message.Receive(ws, &msg); err != nil {
+					return
+				}
+				select {
+				case requests <- msg:
+				case <-stop:
+					return
+				}
+			}
+		}()
+
+		// Subscribed channels of each market, with the sequence they start after
+		subscriptions := map[string]uint64{}
+		for {
+			var reply *events.Event
+			select {
+			case <-done:
+				return
+			case msg := <-requests:
+				reply = handleStreamRequest(c, msg, subscriptions)
+			case event, ok := <-eventCh:
+				if !ok {
+					return
+				}
+				channel := eventChannel(&event)
+				after, subscribed := subscriptions[event.Symbol+"/"+channel]
+				if len(channel) == 0 || !subscribed || event.Sequence <= after {
+					continue
+				}
+				reply = &event
+			}
+			if err := websocket.JSON.Send(ws, reply); err != nil {
+				return
+			}
+		}
+	}}.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+func handleStreamRequest(c echo.Context, msg string, subscriptions map[string]uint64) *events.Event {
+	ts := uint64(time.Now().UnixNano())
+	req := StreamRequest{}
+	if err := json.Unmarshal([]byte(msg), &req); err != nil {
+		return &events.Event{Type: STREAM_ERROR, Data: utils.ErrResponse{Message: err.Error()}, Timestamp: ts}
+	}
+	if err := c.Validate(&req); err != nil {
+		reply := events.Event{Type: STREAM_ERROR, Symbol: req.Symbol, Data: err, Timestamp: ts}
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			reply.Data = httpErr.Message
+		}
+		return &reply
+	}
+
+	key := req.Symbol + "/" + req.channel()
+	subscribed := Subscribed{Channel: req.Channel, Interval: req.Interval}
+	if req.Op == "unsubscribe" {
+		delete(subscriptions, key)
+		return &events.Event{Type: UNSUBSCRIBED, Symbol: req.Symbol, Data: subscribed, Timestamp: ts}
+	}
+
+	seq, book, err := channelSequence(&req)
+	if err != nil {
+		return &events.Event{
+			Type:      STREAM_ERROR,
+			Symbol:    req.Symbol,
+			Data:      utils.ErrResponse{Message: http.StatusText(http.StatusInternalServerError)},
+			Timestamp: ts,
+		}
+	}
+	subscriptions[key] = seq
+	if book != nil {
+		return &events.Event{Type: BOOK_SNAPSHOT, Symbol: req.Symbol, Sequence: seq, Data: book, Timestamp: book.Timestamp}
+	}
+	return &events.Event{Type: SUBSCRIBED, Symbol: req.Symbol, Sequence: seq, Data: subscribed, Timestamp: ts}
+}
@@ -0,0 +1,68 @@
+package trade
+
+import (
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/market"
+
+	"github.com/linxGnu/grocksdb"
+)
+
+// openOrders counts the orders resting on the books by user, so that placing an order doesn't query them.
+// It is loaded from the books on first use. The matching mutex must be locked to use it.
+var openOrders map[uint64]int64
+
+// openOrderUpdates accumulates the changes of the open order counts of a batch, applied once the batch is written
+type openOrderUpdates map[uint64]int64
+
+// rest counts an order put on the book
+func (u openOrderUpdates) rest(userId uint64) {
+	u[userId]++
+}
+
+// leave counts an order removed from the book
+func (u openOrderUpdates) leave(userId uint64) {
+	u[userId]--
+}
+
+// apply changes the open order counts once the batch is written
+func (u openOrderUpdates) apply() {
+	// The counts are loaded with the changes on first use
+	if openOrders == nil {
+		return
+	}
+	for userId, delta := range u {
+		if count := openOrders[userId] + delta; count > 0 {
+			openOrders[userId] = count
+		} else {
+			delete(openOrders, userId)
+		}
+	}
+}
+
+// openOrderCount returns the number of orders of the user resting on the books, expired orders not yet removed included
+func openOrderCount(userId uint64) int64 {
+	if openOrders == nil {
+		openOrders = loadOpenOrders()
+	}
+	return openOrders[userId]
+}
+
+func loadOpenOrders() map[uint64]int64 {
+	counts := map[uint64]int64{}
+	ro := grocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+	for _, symbol := range market.Symbols() {
+		orderBook := rocksdb.Book(symbol)
+		for _, side := range []models.OrderType{models.BUY, models.SELL} {
+			it := rocksdb.DB.NewIteratorCF(ro, orderBook.Side(side))
+			for it.SeekToFirst(); it.Valid(); it.Next() {
+				order := models.Order{Type: side}
+				order.ParseKV(it.Key().Data(), nil)
+				counts[order.UserId]++
+			}
+			it.Close()
+		}
+	}
+	return counts
+}
@@ -0,0 +1,37 @@
+package trade
+
+import (
+	"trading-bsx/pkg/db/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// transitionUpdate sets the fields & the status of an order document.
+// The transition is recorded only if the order doesn't have that status yet.
+func transitionUpdate(fields bson.M, status models.OrderStatus, ts uint64) mongo.Pipeline {
+	fields["status"] = status
+	fields["transitions"] = bson.M{
+		"$cond": bson.A{
+			bson.M{"$eq": bson.A{"$status", status}},
+			"$transitions",
+			bson.M{"$concatArrays": bson.A{
+				bson.M{"$ifNull": bson.A{"$transitions", bson.A{}}},
+				bson.A{bson.M{"status": status, "timestamp": ts}},
+			}},
+		},
+	}
+	return mongo.Pipeline{{{Key: "$set", Value: fields}}}
+}
+
+// openOrderFilter matches orders resting on the book, which are not expired at ts
+func openOrderFilter(ts uint64) bson.M {
+	return bson.M{
+		"status": bson.M{"$in": models.OpenStatuses},
+		"$or": []bson.M{
+			{"expired_at": bson.M{"$gte": ts}},
+			{"expired_at": 0},
+			{"expired_at": bson.M{"$exists": false}},
+		},
+	}
+}
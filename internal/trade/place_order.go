@@ -1,13 +1,15 @@
 package trade
 
 import (
-	"fmt"
 	"net/http"
 	"sync"
 	"time"
+	"trading-bsx/internal/account"
 	"trading-bsx/pkg/db/models"
 	"trading-bsx/pkg/db/mongodb"
 	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/events"
+	"trading-bsx/pkg/ledger"
 	"trading-bsx/pkg/utils"
 
 	"github.com/labstack/echo/v4"
@@ -15,13 +17,40 @@ import (
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type CreateOrder struct {
-	Type  models.OrderType `json:"type" validate:"required,oneof=BUY SELL"`
-	Price float64          `json:"price" validate:"required,gt=0"`
-	// Good till time, in milliseconds
+	Symbol    string           `json:"symbol,omitempty" param:"symbol" validate:"required,valid_symbol"`
+	Type      models.OrderType `json:"type" validate:"required,oneof=BUY SELL"`
+	OrderType models.OrderKind `json:"orderType,omitempty" validate:"omitempty,oneof=LIMIT MARKET"`
+	// Limit price. For market orders, it is the optional slippage protection price
+	Price    models.Decimal `json:"price" validate:"required_unless=OrderType MARKET,omitempty,price"`
+	Quantity models.Decimal `json:"quantity" validate:"quantity"`
+	// GTC by default. IOC for market orders
+	TimeInForce models.TimeInForce `json:"timeInForce,omitempty" validate:"omitempty,oneof=GTC IOC FOK POST_ONLY GTD"`
+	// Good till time, in milliseconds. Relative to the order time
 	GTT *uint64 `json:"gtt,omitempty" validate:"omitempty,gt=0"`
+	// Expire time of GTD orders, as Unix timestamp in milliseconds
+	ExpireTime *uint64 `json:"expireTime,omitempty" validate:"omitempty,gt=0"`
+	// Self-trade prevention mode. Defaults to the account's mode
+	SelfTradePrevention models.STPMode `json:"selfTradePrevention,omitempty" validate:"omitempty,oneof=CANCEL_NEWEST CANCEL_OLDEST CANCEL_BOTH DECREMENT_AND_CANCEL ALLOW"`
+	// Optional ID of the order, unique per user. A retried request with the same ID returns the first result
+	ClientOrderId string `json:"clientOrderId,omitempty" validate:"omitempty,client_order_id"`
+}
+
+type PlaceOrderResult struct {
+	// The placed order. Its remaining quantity rests on the book, if any
+	Order models.Order   `json:"order"`
+	Fills []models.Trade `json:"fills"`
+	// Actions taken instead of matching orders of the same user
+	SelfTrades []SelfTrade `json:"selfTrades"`
+}
+
+// bookOrder is a resting order together with its raw key in the order book
+type bookOrder struct {
+	key   []byte
+	order models.Order
 }
 
 var mutex = sync.Mutex{}
@@ -29,140 +58,408 @@ var mutex = sync.Mutex{}
 func PlaceOrder(c echo.Context) error {
 	body := CreateOrder{}
 	if err := utils.BindNValidate(c, &body); err != nil {
-		fmt.Println(err)
+		log.Error().Err(err).Msg("Invalid order")
 		return err
 	}
-
-	var book *grocksdb.DB
-	var opponentBook *grocksdb.DB
-	var matchOrder *models.Order
-	var matchOrderKey []byte
-
-	order := models.Order{
-		UserId:    c.Get("userId").(uint64),
-		Type:      body.Type,
-		Price:     body.Price,
-		Timestamp: uint64(time.Now().UnixNano()),
-		ExpiredAt: nil,
+	if symbol := c.Param("symbol"); len(symbol) > 0 && symbol != body.Symbol {
+		return echo.NewHTTPError(http.StatusBadRequest, "Symbol does not match the market")
 	}
-	if body.GTT != nil {
-		tmp := *body.GTT*uint64(time.Millisecond) + order.Timestamp
-		order.ExpiredAt = &tmp
+	idempotencyKey := c.Request().Header.Get(IDEMPOTENCY_KEY_HEADER)
+	if len(idempotencyKey) > 255 {
+		return echo.NewHTTPError(http.StatusBadRequest, "Idempotency key must be at most 255 characters long")
 	}
+	userId := c.Get("userId").(uint64)
+	reqCtx := c.Request().Context()
 
 	mutex.Lock()
 	defer mutex.Unlock()
 
+	// A retried request gets the result of the first one
+	placed, err := findPlacedOrder(reqCtx, userId, idempotencyKey, &body)
+	if err != nil {
+		return err
+	}
+	if placed != nil {
+		c.Response().Header().Set(IDEMPOTENT_REPLAYED_HEADER, "true")
+		return c.JSON(http.StatusOK, placed)
+	}
+
+	var matchOrders []bookOrder
+	var expiredOrders []bookOrder
+
+	orderId := primitive.NewObjectID()
+	order := models.Order{
+		ID:                  &orderId,
+		UserId:              userId,
+		ClientOrderId:       body.ClientOrderId,
+		Symbol:              body.Symbol,
+		Type:                body.Type,
+		OrderType:           body.OrderType,
+		Price:               body.Price,
+		Quantity:            body.Quantity,
+		Remaining:           body.Quantity,
+		Timestamp:           uint64(time.Now().UnixNano()),
+		ExpiredAt:           nil,
+		SelfTradePrevention: body.SelfTradePrevention,
+		Version:             1,
+	}
+	order.CreatedAt = order.Timestamp
+	if order.OrderType == "" {
+		order.OrderType = models.LIMIT
+	}
+	if err := applyTimeInForce(&body, &order); err != nil {
+		return err
+	}
+	if order.SelfTradePrevention == "" {
+		userAccount, err := account.FindAccount(reqCtx, order.UserId)
+		if err != nil {
+			return err
+		}
+		order.SelfTradePrevention = userAccount.SelfTradePrevention
+	}
+	order.SetStatus(models.NEW, order.Timestamp)
+	if err := checkMarketRules(reqCtx, &order); err != nil {
+		return err
+	}
+	if err := checkOpenOrders(&order); err != nil {
+		return err
+	}
+
+	orderBook := rocksdb.Book(order.Symbol)
+	var opponentBook *grocksdb.ColumnFamilyHandle
 	if order.Type == models.BUY {
-		book = rocksdb.BuyOrder
-		opponentBook = rocksdb.SellOrder
-		matchOrderKey, matchOrder = getMatchSellOrder(&order)
+		opponentBook = orderBook.SellOrder
+		matchOrders, expiredOrders = getMatchSellOrder(orderBook, &order)
 	} else {
-		book = rocksdb.SellOrder
-		opponentBook = rocksdb.BuyOrder
-		matchOrderKey, matchOrder = getMatchBuyOrder(&order)
+		opponentBook = orderBook.BuyOrder
+		matchOrders, expiredOrders = getMatchBuyOrder(orderBook, &order)
+	}
+	// Checked before any fill, so that a killed or rejected order leaves the book untouched
+	rejected := false
+	switch order.TimeInForce {
+	case models.FOK:
+		if matchedQuantity(&order, matchOrders).Cmp(order.Remaining) < 0 {
+			matchOrders = nil
+		}
+	case models.POST_ONLY:
+		if len(matchOrders) > 0 {
+			matchOrders = nil
+			rejected = true
+		}
 	}
 
 	log.Info().Interface("order", order).Msg("Place order")
-	log.Info().Interface("matchOrder", matchOrder).Msg("Match order")
 
 	wo := grocksdb.NewDefaultWriteOptions()
 	defer wo.Destroy()
+	batch := grocksdb.NewWriteBatch()
+	defer batch.Destroy()
 
-	reqCtx := c.Request().Context()
-	if matchOrder != nil {
-		if err := opponentBook.Delete(wo, matchOrderKey); err != nil {
+	balances := balanceUpdates{}
+	journal := ledger.Journal{}
+	counts := openOrderUpdates{}
+	for i := range expiredOrders {
+		orderBook.Delete(batch, &expiredOrders[i].order, expiredOrders[i].key)
+		counts.leave(expiredOrders[i].order.UserId)
+		if err := balances.releaseOrder(&expiredOrders[i].order); err != nil {
 			return err
 		}
-		mongodb.Order.DeleteOne(reqCtx, bson.M{"key": matchOrder.Key})
-		return c.JSON(http.StatusOK, matchOrder)
 	}
 
-	orderKey, orderValue := order.ToKVBytes()
-	if err := book.Put(wo, orderKey, orderValue); err != nil {
+	tradeSeq, err := rocksdb.LastSequence(tradeSequenceName(order.Symbol))
+	if err != nil {
 		return err
 	}
-	result, err := mongodb.Order.InsertOne(reqCtx, order)
-	if err != nil {
+	fills := make([]models.Trade, 0, len(matchOrders))
+	selfTrades := make([]SelfTrade, 0)
+	selfTradeCancelled := false
+	for i := range matchOrders {
+		matchOrder := &matchOrders[i].order
+		if isSelfTrade(&order, matchOrder) {
+			selfTrade, cancelled, err := preventSelfTrade(batch, orderBook, balances, counts, &order, &matchOrders[i])
+			if err != nil {
+				return err
+			}
+			selfTrades = append(selfTrades, selfTrade)
+			selfTradeCancelled = selfTradeCancelled || cancelled
+			log.Info().Interface("matchOrder", matchOrder).Str("mode", string(selfTrade.Mode)).Msg("Prevent self-trade")
+			continue
+		}
+		quantity := models.MinDecimal(order.Remaining, matchOrder.Remaining)
+		before := matchOrder.Remaining
+		order.Remaining = order.Remaining.Sub(quantity)
+		matchOrder.Remaining = matchOrder.Remaining.Sub(quantity)
+		tradeSeq++
+		fill := models.Trade{
+			Symbol:       order.Symbol,
+			Sequence:     tradeSeq,
+			TakerOrderId: orderId,
+			MakerUserId:  matchOrder.UserId,
+			TakerUserId:  order.UserId,
+			Price:        matchOrder.Price,
+			Quantity:     quantity,
+			Side:         order.Type,
+			Timestamp:    order.Timestamp,
+		}
+		// A taker without enough funds is rejected before anything is written
+		if err := balances.settleFill(&journal, &fill, matchOrder, before); err != nil {
+			return err
+		}
+		fills = append(fills, fill)
+
+		if matchOrder.Remaining.Sign() > 0 {
+			_, value := matchOrder.ToKVBytes()
+			batch.PutCF(opponentBook, matchOrders[i].key, value)
+		} else {
+			orderBook.Delete(batch, matchOrder, matchOrders[i].key)
+			counts.leave(matchOrder.UserId)
+		}
+		log.Info().Interface("matchOrder", matchOrder).Stringer("quantity", quantity).Msg("Match order")
+	}
+	status := order.FillStatus()
+	if selfTradeCancelled && status == models.FILLED {
+		// Decremented to zero by self-trade prevention, it isn't filled
+		status = models.CANCELLED
+	}
+	order.SetStatus(status, order.Timestamp)
+	if rejected {
+		order.SetStatus(models.REJECTED, order.Timestamp)
+	} else if order.IsOpen() && (selfTradeCancelled || !order.CanRest()) {
+		order.SetStatus(models.CANCELLED, order.Timestamp)
+	}
+	if order.IsOpen() {
+		orderBook.Put(batch, &order)
+		counts.rest(order.UserId)
+		if err := balances.lockOrder(&order); err != nil {
+			return err
+		}
+	}
+	if err := balances.write(batch); err != nil {
 		return err
 	}
-	return c.String(http.StatusOK, result.InsertedID.(primitive.ObjectID).Hex())
+	if err := journal.Write(batch); err != nil {
+		return err
+	}
+	rocksdb.SetSequence(batch, tradeSequenceName(order.Symbol), tradeSeq)
+	if err := rocksdb.DB.Write(wo, batch); err != nil {
+		return err
+	}
+	counts.apply()
+	if order.IsOpen() && order.ExpiredAt != nil {
+		wakeExpiryReaper()
+	}
+
+	// The order is placed once the batch is written, failing to store it in MongoDB doesn't fail the request
+	if len(expiredOrders) > 0 {
+		expiredKeys := make([]string, len(expiredOrders))
+		for i := range expiredOrders {
+			expiredKeys[i] = expiredOrders[i].order.Key
+		}
+		markExpired(reqCtx, order.Symbol, expiredKeys, order.Timestamp)
+	}
+	// Resting orders are returned as updated, to be published
+	after := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	makerEvents := make([]events.Event, 0, len(matchOrders))
+	fillIndex, selfTradeIndex := 0, 0
+	for i := range matchOrders {
+		// A closed order is never moved back to an open status
+		makerFilter := bson.M{
+			"symbol": order.Symbol,
+			"key":    matchOrders[i].order.Key,
+			"status": bson.M{"$in": models.OpenStatuses},
+		}
+		matchOrder := models.Order{}
+		if isSelfTrade(&order, &matchOrders[i].order) {
+			selfTrade := &selfTrades[selfTradeIndex]
+			selfTradeIndex++
+			update := selfTradeUpdate(*selfTrade, &matchOrders[i].order, order.Timestamp)
+			if update == nil {
+				if err := mongodb.Order.FindOne(reqCtx, makerFilter).Decode(&matchOrder); err != nil {
+					log.Err(err).Str("key", matchOrders[i].order.Key).Msg("Find self-trade order")
+					continue
+				}
+			} else {
+				if err := mongodb.Order.FindOneAndUpdate(reqCtx, makerFilter, update, after).Decode(&matchOrder); err != nil {
+					log.Err(err).Str("key", matchOrders[i].order.Key).Msg("Store self-trade order")
+					continue
+				}
+				if matchOrder.IsOpen() {
+					// Decremented
+					makerEvents = append(makerEvents, orderEvent(events.ORDER_AMENDED, &matchOrder, order.Timestamp))
+				} else {
+					makerEvents = append(makerEvents, orderStatusEvent(&matchOrder, order.Timestamp))
+				}
+			}
+			selfTrade.MakerOrderId = *matchOrder.ID
+			continue
+		}
+
+		status := models.FILLED
+		if matchOrders[i].order.Remaining.Sign() > 0 {
+			status = models.PARTIALLY_FILLED
+		}
+		fill := &fills[fillIndex]
+		fillIndex++
+		if err := mongodb.Order.FindOneAndUpdate(reqCtx, makerFilter, transitionUpdate(bson.M{
+			"remaining": matchOrders[i].order.Remaining,
+		}, status, order.Timestamp), after).Decode(&matchOrder); err != nil {
+			log.Err(err).Str("key", matchOrders[i].order.Key).Msg("Store maker order")
+			continue
+		}
+		makerEvents = append(makerEvents, orderStatusEvent(&matchOrder, order.Timestamp))
+		fill.MakerOrderId = *matchOrder.ID
+	}
+	if len(fills) > 0 {
+		trades := make([]interface{}, len(fills))
+		for i := range fills {
+			trades[i] = fills[i]
+		}
+		if _, err := mongodb.Trade.InsertMany(reqCtx, trades); err != nil {
+			log.Err(err).Interface("trades", fills).Msg("Store trades")
+		}
+		recordTrades(reqCtx, order.Symbol, fills)
+		// Candles are derived from the trades, failing to record them doesn't fail the request
+		if err := recordCandles(reqCtx, order.Symbol, fills); err != nil {
+			log.Err(err).Str("symbol", order.Symbol).Msg("Record candles")
+		}
+	}
+
+	if _, err := mongodb.Order.InsertOne(reqCtx, order); err != nil {
+		log.Err(err).Interface("order", order).Msg("Store order")
+	}
+
+	userEvents := make([]events.Event, 0, 2+2*len(fills)+len(makerEvents))
+	if order.Status != models.REJECTED {
+		userEvents = append(userEvents, orderEvent(events.ORDER_ACCEPTED, &order, order.Timestamp))
+	}
+	userEvents = append(userEvents, fillEvents(fills)...)
+	userEvents = append(userEvents, makerEvents...)
+	if order.Status != models.NEW {
+		userEvents = append(userEvents, orderStatusEvent(&order, order.Timestamp))
+	}
+	publishUserEvents(reqCtx, userEvents)
+
+	publishTrades(order.Symbol, fills)
+	changedLevels := make([]models.Order, 0, len(matchOrders)+len(expiredOrders)+1)
+	for _, bookOrders := range [][]bookOrder{matchOrders, expiredOrders} {
+		for i := range bookOrders {
+			changedLevels = append(changedLevels, bookOrders[i].order)
+		}
+	}
+	if order.IsOpen() {
+		changedLevels = append(changedLevels, order)
+	}
+	publishBookUpdate(reqCtx, order.Symbol, changedLevels)
+	if len(fills) > 0 {
+		publishCandles(reqCtx, order.Symbol, order.Timestamp)
+	}
+
+	result := PlaceOrderResult{
+		Order:      order,
+		Fills:      fills,
+		SelfTrades: selfTrades,
+	}
+	// The order is placed, so the request doesn't fail when its result can't be kept for retries
+	if err := storePlacedOrder(reqCtx, idempotencyKey, &body, &result); err != nil {
+		log.Err(err).Interface("order", order).Str("idempotencyKey", idempotencyKey).Msg("Store placed order")
+	}
+	return c.JSON(http.StatusOK, result)
 }
 
-func getMatchBuyOrder(order *models.Order) ([]byte, *models.Order) {
-	// Sell -> Get biggest buy order -> Seek from the last item in the list
+// getMatchBuyOrder returns the crossing buy orders, best first, until their quantity covers the order.
+// Crossing orders of the same user are included, as the self-trade prevention mode requires.
+// Expired orders seen along the way are returned separately, to be removed from the book.
+func getMatchBuyOrder(orderBook *rocksdb.OrderBook, order *models.Order) ([]bookOrder, []bookOrder) {
+	// Sell -> Get biggest buy orders -> Seek from the last item in the list
 	ro := grocksdb.NewDefaultReadOptions()
 	defer ro.Destroy()
-	it := rocksdb.BuyOrder.NewIterator(ro)
+	it := rocksdb.DB.NewIteratorCF(ro, orderBook.BuyOrder)
 	defer it.Close()
 
+	matchOrders := make([]bookOrder, 0)
+	expiredOrders := make([]bookOrder, 0)
+	quantity := models.Decimal{}
 	it.SeekToLast()
-	for it.Valid() {
-		k, v := it.Key().Data(), it.Value().Data()
+	for it.Valid() && quantity.Cmp(order.Remaining) < 0 {
+		// Iterator memory is reused after moving, so the key must be copied
+		k := append([]byte{}, it.Key().Data()...)
+		v := it.Value().Data()
 		matchOrder := models.Order{
-			Type: models.BUY,
+			Symbol: order.Symbol,
+			Type:   models.BUY,
 		}
 		matchOrder.ParseKV(k, v)
-		if matchOrder.UserId == order.UserId {
+		if matchOrder.IsExpired(order.Timestamp) {
+			expiredOrders = append(expiredOrders, bookOrder{key: k, order: matchOrder})
 			it.Prev()
 			continue
 		}
-		if matchOrder.ExpiredAt != nil && *matchOrder.ExpiredAt > 0 {
-			if uint64(time.Now().UnixNano()) > *matchOrder.ExpiredAt {
-				wo := grocksdb.NewDefaultWriteOptions()
-				defer wo.Destroy()
-				if err := rocksdb.BuyOrder.Delete(wo, k); err != nil {
-					fmt.Println(err)
-				}
+		if !order.Price.IsZero() && matchOrder.Price.Cmp(order.Price) < 0 {
+			// The biggest buy order is smaller than the current order, so no need to continue
+			break
+		}
+		matchOrders = append(matchOrders, bookOrder{key: k, order: matchOrder})
+		if isSelfTrade(order, &matchOrder) {
+			if order.SelfTradePrevention == models.CANCEL_NEWEST || order.SelfTradePrevention == models.CANCEL_BOTH {
+				// The current order is cancelled here
+				break
+			}
+			if order.SelfTradePrevention == models.CANCEL_OLDEST {
 				it.Prev()
 				continue
 			}
 		}
-		if matchOrder.Price >= order.Price {
-			return k, &matchOrder
-		}
-
-		// The biggest buy order is smaller than the current order, so no need to continue
-		return nil, nil
+		quantity = quantity.Add(matchOrder.Remaining)
+		it.Prev()
 	}
-	return nil, nil
+	return matchOrders, expiredOrders
 }
 
-func getMatchSellOrder(order *models.Order) ([]byte, *models.Order) {
-	// Buy -> Get smallest sell order -> Seek from the first item in the list
+// getMatchSellOrder returns the crossing sell orders, best first, until their quantity covers the order.
+// Crossing orders of the same user are included, as the self-trade prevention mode requires.
+// Expired orders seen along the way are returned separately, to be removed from the book.
+func getMatchSellOrder(orderBook *rocksdb.OrderBook, order *models.Order) ([]bookOrder, []bookOrder) {
+	// Buy -> Get smallest sell orders -> Seek from the first item in the list
 	ro := grocksdb.NewDefaultReadOptions()
 	defer ro.Destroy()
-	it := rocksdb.SellOrder.NewIterator(ro)
+	it := rocksdb.DB.NewIteratorCF(ro, orderBook.SellOrder)
 	defer it.Close()
 
+	matchOrders := make([]bookOrder, 0)
+	expiredOrders := make([]bookOrder, 0)
+	quantity := models.Decimal{}
 	it.SeekToFirst()
-	for it.Valid() {
-		k, v := it.Key().Data(), it.Value().Data()
+	for it.Valid() && quantity.Cmp(order.Remaining) < 0 {
+		// Iterator memory is reused after moving, so the key must be copied
+		k := append([]byte{}, it.Key().Data()...)
+		v := it.Value().Data()
 		matchOrder := models.Order{
-			Type: models.SELL,
+			Symbol: order.Symbol,
+			Type:   models.SELL,
 		}
 		matchOrder.ParseKV(k, v)
-		if matchOrder.UserId == order.UserId {
+		if matchOrder.IsExpired(order.Timestamp) {
+			expiredOrders = append(expiredOrders, bookOrder{key: k, order: matchOrder})
 			it.Next()
 			continue
 		}
-		if matchOrder.ExpiredAt != nil && *matchOrder.ExpiredAt > 0 {
-			if uint64(time.Now().UnixNano()) > *matchOrder.ExpiredAt {
-				wo := grocksdb.NewDefaultWriteOptions()
-				defer wo.Destroy()
-				if err := rocksdb.SellOrder.Delete(wo, k); err != nil {
-					fmt.Println(err)
-				}
+		if !order.Price.IsZero() && matchOrder.Price.Cmp(order.Price) > 0 {
+			// The smallest sell order is bigger than the current order, so no need to continue
+			break
+		}
+		matchOrders = append(matchOrders, bookOrder{key: k, order: matchOrder})
+		if isSelfTrade(order, &matchOrder) {
+			if order.SelfTradePrevention == models.CANCEL_NEWEST || order.SelfTradePrevention == models.CANCEL_BOTH {
+				// The current order is cancelled here
+				break
+			}
+			if order.SelfTradePrevention == models.CANCEL_OLDEST {
 				it.Next()
 				continue
 			}
 		}
-		if matchOrder.Price <= order.Price {
-			return k, &matchOrder
-		}
-
-		// The smallest sell order is bigger than the current order, so no need to continue
-		return nil, nil
+		quantity = quantity.Add(matchOrder.Remaining)
+		it.Next()
 	}
-	return nil, nil
+	return matchOrders, expiredOrders
 }
@@ -0,0 +1,77 @@
+package trade
+
+import (
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/rocksdb"
+
+	"github.com/linxGnu/grocksdb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SelfTrade is the action taken instead of matching 2 orders of the same user
+type SelfTrade struct {
+	Mode         models.STPMode     `json:"mode"`
+	MakerOrderId primitive.ObjectID `json:"makerOrderId"`
+	// Quantity taken off both orders by DECREMENT_AND_CANCEL
+	Quantity models.Decimal `json:"quantity"`
+}
+
+// isSelfTrade reports whether the resting order must not match the incoming order
+func isSelfTrade(order *models.Order, matchOrder *models.Order) bool {
+	return matchOrder.UserId == order.UserId && order.SelfTradePrevention != models.ALLOW_SELF_TRADE
+}
+
+// preventSelfTrade applies the self-trade prevention mode of the incoming order to a resting order of the same user,
+// unlocking the funds the resting order no longer needs and counting it out of the open orders once removed from the book.
+// It returns the action taken and whether the incoming order is cancelled.
+func preventSelfTrade(batch *grocksdb.WriteBatch, orderBook *rocksdb.OrderBook, balances balanceUpdates, counts openOrderUpdates, order *models.Order, matchOrder *bookOrder) (SelfTrade, bool, error) {
+	selfTrade := SelfTrade{Mode: order.SelfTradePrevention}
+	order.SelfTradeAction = order.SelfTradePrevention
+	switch order.SelfTradePrevention {
+	case models.CANCEL_OLDEST:
+		orderBook.Delete(batch, &matchOrder.order, matchOrder.key)
+		counts.leave(matchOrder.order.UserId)
+		return selfTrade, false, balances.releaseOrder(&matchOrder.order)
+	case models.CANCEL_BOTH:
+		orderBook.Delete(batch, &matchOrder.order, matchOrder.key)
+		counts.leave(matchOrder.order.UserId)
+		return selfTrade, true, balances.releaseOrder(&matchOrder.order)
+	case models.DECREMENT_AND_CANCEL:
+		quantity := models.MinDecimal(order.Remaining, matchOrder.order.Remaining)
+		before := matchOrder.order.Remaining
+		order.Quantity = order.Quantity.Sub(quantity)
+		order.Remaining = order.Remaining.Sub(quantity)
+		matchOrder.order.Remaining = matchOrder.order.Remaining.Sub(quantity)
+		if matchOrder.order.Remaining.Sign() > 0 {
+			_, value := matchOrder.order.ToKVBytes()
+			batch.PutCF(orderBook.Side(matchOrder.order.Type), matchOrder.key, value)
+		} else {
+			orderBook.Delete(batch, &matchOrder.order, matchOrder.key)
+			counts.leave(matchOrder.order.UserId)
+		}
+		selfTrade.Quantity = quantity
+		return selfTrade, order.Remaining.Sign() == 0, balances.reduceOrder(&matchOrder.order, before)
+	}
+	// CANCEL_NEWEST leaves the resting order untouched
+	return selfTrade, true, nil
+}
+
+// selfTradeUpdate records the self-trade prevention action on the resting order.
+// It returns nil if the resting order is untouched.
+func selfTradeUpdate(selfTrade SelfTrade, matchOrder *models.Order, ts uint64) mongo.Pipeline {
+	fields := bson.M{"self_trade_action": selfTrade.Mode}
+	switch selfTrade.Mode {
+	case models.CANCEL_OLDEST, models.CANCEL_BOTH:
+		return transitionUpdate(fields, models.CANCELLED, ts)
+	case models.DECREMENT_AND_CANCEL:
+		fields["remaining"] = matchOrder.Remaining
+		fields["quantity"] = bson.M{"$subtract": bson.A{"$quantity", selfTrade.Quantity}}
+		if matchOrder.Remaining.Sign() > 0 {
+			return mongo.Pipeline{{{Key: "$set", Value: fields}}}
+		}
+		return transitionUpdate(fields, models.CANCELLED, ts)
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package trade
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/mongodb"
+	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/linxGnu/grocksdb"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const statsWindow = uint64(24 * time.Hour)
+const statsBucketSize = uint64(time.Minute)
+const statsBucketCount = statsWindow / statsBucketSize
+
+// statsBucket aggregates the trades of a minute
+type statsBucket struct {
+	start       uint64
+	open        models.Decimal
+	high        models.Decimal
+	low         models.Decimal
+	close       models.Decimal
+	volume      models.Decimal
+	quoteVolume models.Decimal
+}
+
+// marketStats keeps the last 24h of trades of a market, folded trade by trade into a ring of minute buckets
+type marketStats struct {
+	buckets   [statsBucketCount]statsBucket
+	lastTrade *models.Trade
+	// Sequence of the last folded trade
+	sequence uint64
+}
+
+var statsMutex = sync.RWMutex{}
+var stats = map[string]*marketStats{}
+
+// Init clears the state kept in memory, it is loaded again from the databases when needed
+func Init() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	eventSequences = map[string]uint64{}
+	openOrders = nil
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	stats = map[string]*marketStats{}
+}
+
+func (s *marketStats) add(trade *models.Trade) {
+	if trade.Sequence <= s.sequence {
+		return
+	}
+	s.sequence = trade.Sequence
+	lastTrade := *trade
+	s.lastTrade = &lastTrade
+
+	start := trade.Timestamp - trade.Timestamp%statsBucketSize
+	bucket := &s.buckets[start/statsBucketSize%statsBucketCount]
+	if bucket.start != start {
+		*bucket = statsBucket{start: start, open: trade.Price, high: trade.Price, low: trade.Price}
+	}
+	bucket.high = models.MaxDecimal(bucket.high, trade.Price)
+	bucket.low = models.MinDecimal(bucket.low, trade.Price)
+	bucket.close = trade.Price
+	bucket.volume = bucket.volume.Add(trade.Quantity)
+	bucket.quoteVolume = bucket.quoteVolume.Add(trade.Price.Mul(trade.Quantity))
+}
+
+// fill sets the statistics of the 24h before ts, at a minute resolution
+func (s *marketStats) fill(ticker *Ticker, ts uint64) {
+	if s.lastTrade == nil {
+		return
+	}
+	lastPrice := s.lastTrade.Price
+	ticker.LastPrice = &lastPrice
+	ticker.LastTradeTime = s.lastTrade.Timestamp
+
+	current := ts - ts%statsBucketSize
+	oldest := current - (statsBucketCount-1)*statsBucketSize
+	for i := uint64(1); i <= statsBucketCount; i++ {
+		bucket := &s.buckets[(current/statsBucketSize+i)%statsBucketCount]
+		if bucket.start < oldest || bucket.start > current || bucket.volume.IsZero() {
+			continue
+		}
+		// Values are copied, buckets change once the lock is released
+		open, high, low, last := bucket.open, bucket.high, bucket.low, bucket.close
+		if ticker.Open == nil {
+			ticker.Open = &open
+		} else {
+			high = models.MaxDecimal(*ticker.High, high)
+			low = models.MinDecimal(*ticker.Low, low)
+		}
+		ticker.High, ticker.Low, ticker.Close = &high, &low, &last
+		ticker.Volume = ticker.Volume.Add(bucket.volume)
+		ticker.QuoteVolume = ticker.QuoteVolume.Add(bucket.quoteVolume)
+	}
+	if ticker.Open != nil {
+		change := ticker.Close.Sub(*ticker.Open)
+		changePercent := change.Mul(models.NewDecimalFromInt(100)).Quo(*ticker.Open)
+		ticker.Change, ticker.ChangePercent = &change, &changePercent
+	}
+}
+
+// loadedStats returns the statistics of the market, loading the last 24h of trades on first use.
+// statsMutex must be locked.
+func loadedStats(ctx context.Context, symbol string) (*marketStats, error) {
+	if s, ok := stats[symbol]; ok {
+		return s, nil
+	}
+
+	s := &marketStats{}
+	ts := uint64(time.Now().UnixNano())
+	filter := bson.M{"symbol": symbol}
+	if ts > statsWindow {
+		filter["timestamp"] = bson.M{"$gte": ts - statsWindow}
+	}
+	cursor, err := mongodb.Trade.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		trade := models.Trade{}
+		if err := cursor.Decode(&trade); err != nil {
+			return nil, err
+		}
+		s.add(&trade)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	if s.lastTrade == nil {
+		// The last price is kept even if the market didn't trade for 24h
+		lastTrade := models.Trade{}
+		err := mongodb.Trade.FindOne(ctx, bson.M{"symbol": symbol},
+			options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}}),
+		).Decode(&lastTrade)
+		if err == nil {
+			s.add(&lastTrade)
+		}
+	}
+
+	stats[symbol] = s
+	return s, nil
+}
+
+// lastTradePrice returns the price of the last trade of the market, or nil if it never traded
+func lastTradePrice(ctx context.Context, symbol string) (*models.Decimal, error) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	s, err := loadedStats(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	if s.lastTrade == nil {
+		return nil, nil
+	}
+	price := s.lastTrade.Price
+	return &price, nil
+}
+
+// recordTrades folds new trades of a market into its statistics
+func recordTrades(ctx context.Context, symbol string, trades []models.Trade) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	s, err := loadedStats(ctx, symbol)
+	if err != nil {
+		// The trades are stored, they are folded when the statistics are loaded
+		log.Err(err).Str("symbol", symbol).Msg("Load market statistics")
+		return
+	}
+	for i := range trades {
+		s.add(&trades[i])
+	}
+}
+
+type GetTickerQuery struct {
+	Symbol string `param:"symbol" query:"symbol" validate:"required,valid_symbol"`
+}
+
+type Ticker struct {
+	Symbol          string          `json:"symbol"`
+	BestBid         *models.Decimal `json:"bestBid,omitempty"`
+	BestBidQuantity *models.Decimal `json:"bestBidQuantity,omitempty"`
+	BestAsk         *models.Decimal `json:"bestAsk,omitempty"`
+	BestAskQuantity *models.Decimal `json:"bestAskQuantity,omitempty"`
+	Spread          *models.Decimal `json:"spread,omitempty"`
+	LastPrice       *models.Decimal `json:"lastPrice,omitempty"`
+	LastTradeTime   uint64          `json:"lastTradeTime,omitempty"`
+
+	// Statistics of the last 24h, at a minute resolution. Prices are missing if there was no trade.
+	Open          *models.Decimal `json:"open,omitempty"`
+	High          *models.Decimal `json:"high,omitempty"`
+	Low           *models.Decimal `json:"low,omitempty"`
+	Close         *models.Decimal `json:"close,omitempty"`
+	Volume        models.Decimal  `json:"volume"`
+	QuoteVolume   models.Decimal  `json:"quoteVolume"`
+	Change        *models.Decimal `json:"change,omitempty"`
+	ChangePercent *models.Decimal `json:"changePercent,omitempty"`
+
+	Timestamp uint64 `json:"timestamp"`
+}
+
+// GetTicker returns the top of the book & the 24h statistics of a market
+func GetTicker(c echo.Context) error {
+	req := GetTickerQuery{}
+	if err := utils.BindNValidate(c, &req); err != nil {
+		return err
+	}
+	ticker, err := marketTicker(c.Request().Context(), req.Symbol)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, ticker)
+}
+
+func marketTicker(ctx context.Context, symbol string) (Ticker, error) {
+	ticker := Ticker{
+		Symbol:    symbol,
+		Timestamp: uint64(time.Now().UnixNano()),
+	}
+
+	snapshot := rocksdb.DB.NewSnapshot()
+	defer rocksdb.DB.ReleaseSnapshot(snapshot)
+	ro := grocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+	ro.SetSnapshot(snapshot)
+	orderBook := rocksdb.Book(symbol)
+	top := &GetOrderBookQuery{Depth: 1}
+	if bids := readBookLevels(ro, orderBook.BuyOrder, &models.Order{Symbol: symbol, Type: models.BUY}, top, ticker.Timestamp); len(bids) > 0 {
+		ticker.BestBid, ticker.BestBidQuantity = &bids[0].Price, &bids[0].Quantity
+	}
+	if asks := readBookLevels(ro, orderBook.SellOrder, &models.Order{Symbol: symbol, Type: models.SELL}, top, ticker.Timestamp); len(asks) > 0 {
+		ticker.BestAsk, ticker.BestAskQuantity = &asks[0].Price, &asks[0].Quantity
+	}
+	if ticker.BestBid != nil && ticker.BestAsk != nil {
+		spread := ticker.BestAsk.Sub(*ticker.BestBid)
+		ticker.Spread = &spread
+	}
+
+	statsMutex.RLock()
+	s, ok := stats[symbol]
+	if ok {
+		s.fill(&ticker, ticker.Timestamp)
+	}
+	statsMutex.RUnlock()
+	if !ok {
+		statsMutex.Lock()
+		defer statsMutex.Unlock()
+		s, err := loadedStats(ctx, symbol)
+		if err != nil {
+			return ticker, err
+		}
+		s.fill(&ticker, ticker.Timestamp)
+	}
+	return ticker, nil
+}
@@ -0,0 +1,77 @@
+package trade
+
+import (
+	"math"
+	"net/http"
+	"time"
+	"trading-bsx/pkg/db/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// applyTimeInForce sets the time in force & the expiration time of a new order.
+// Without an explicit time in force, market orders are IOC, orders with an expiration are GTD and others are GTC.
+func applyTimeInForce(body *CreateOrder, order *models.Order) error {
+	hasExpiry := body.GTT != nil || body.ExpireTime != nil
+	tif := body.TimeInForce
+	if tif == "" {
+		switch {
+		case order.OrderType == models.MARKET:
+			tif = models.IOC
+		case hasExpiry:
+			tif = models.GTD
+		default:
+			tif = models.GTC
+		}
+	}
+
+	if order.OrderType == models.MARKET && tif != models.IOC && tif != models.FOK {
+		return echo.NewHTTPError(http.StatusBadRequest, "Market orders must be IOC or FOK")
+	}
+	if tif != models.GTD && hasExpiry {
+		return echo.NewHTTPError(http.StatusBadRequest, "Expiration time is only allowed for GTD orders")
+	}
+	if tif == models.GTD && !hasExpiry {
+		return echo.NewHTTPError(http.StatusBadRequest, "GTD orders require an expire time or a good till time")
+	}
+	if body.GTT != nil && body.ExpireTime != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Only one of expire time and good till time can be set")
+	}
+
+	if body.GTT != nil {
+		expiredAt := *body.GTT*uint64(time.Millisecond) + order.Timestamp
+		order.ExpiredAt = &expiredAt
+	}
+	if body.ExpireTime != nil {
+		expiredAt, err := parseExpireTime(*body.ExpireTime, order.Timestamp)
+		if err != nil {
+			return err
+		}
+		order.ExpiredAt = &expiredAt
+	}
+	order.TimeInForce = tif
+	return nil
+}
+
+// parseExpireTime converts an expire time in milliseconds to nanoseconds, which must be after ts
+func parseExpireTime(expireTime uint64, ts uint64) (uint64, error) {
+	if expireTime > math.MaxUint64/uint64(time.Millisecond) {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Expire time is too far in the future")
+	}
+	expiredAt := expireTime * uint64(time.Millisecond)
+	if expiredAt <= ts {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "Expire time must be in the future")
+	}
+	return expiredAt, nil
+}
+
+// matchedQuantity returns the total remaining quantity of the matched orders, which can trade with the order
+func matchedQuantity(order *models.Order, matchOrders []bookOrder) models.Decimal {
+	quantity := models.Decimal{}
+	for i := range matchOrders {
+		if !isSelfTrade(order, &matchOrders[i].order) {
+			quantity = quantity.Add(matchOrders[i].order.Remaining)
+		}
+	}
+	return quantity
+}
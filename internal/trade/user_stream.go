@@ -0,0 +1,256 @@
+package trade
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"time"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/mongodb"
+	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/events"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/linxGnu/grocksdb"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/net/websocket"
+)
+
+var orderEventTypes = map[models.OrderStatus]events.EventType{
+	models.NEW:              events.ORDER_ACCEPTED,
+	models.PARTIALLY_FILLED: events.ORDER_PARTIALLY_FILLED,
+	models.FILLED:           events.ORDER_FILLED,
+	models.CANCELLED:        events.ORDER_CANCELLED,
+	models.EXPIRED:          events.ORDER_EXPIRED,
+	models.REJECTED:         events.ORDER_REJECTED,
+}
+
+// storedEvent is an event of a user, stored to be replayed
+type storedEvent struct {
+	UserId    uint64           `bson:"user_id"`
+	Sequence  uint64           `bson:"sequence"`
+	Type      events.EventType `bson:"type"`
+	Symbol    string           `bson:"symbol"`
+	Data      interface{}      `bson:"data"`
+	Timestamp uint64           `bson:"timestamp"`
+	CreatedAt time.Time        `bson:"created_at"`
+}
+
+// replayedEvent is a stored event, whose data is decoded according to its type
+type replayedEvent struct {
+	UserId    uint64           `bson:"user_id"`
+	Sequence  uint64           `bson:"sequence"`
+	Type      events.EventType `bson:"type"`
+	Symbol    string           `bson:"symbol"`
+	Data      bson.Raw         `bson:"data"`
+	Timestamp uint64           `bson:"timestamp"`
+}
+
+func userSequenceName(userId uint64) string {
+	return "users/" + strconv.FormatUint(userId, 10)
+}
+
+// orderEvent is an event of the owner of the order, whose data is the order after the change
+func orderEvent(eventType events.EventType, order *models.Order, ts uint64) events.Event {
+	return events.Event{
+		Type:      eventType,
+		Symbol:    order.Symbol,
+		UserId:    order.UserId,
+		Data:      *order,
+		Timestamp: ts,
+	}
+}
+
+// orderStatusEvent is the event of the current status of the order
+func orderStatusEvent(order *models.Order, ts uint64) events.Event {
+	return orderEvent(orderEventTypes[order.Status], order, ts)
+}
+
+// fillEvents are the events of the maker & the taker of each fill
+func fillEvents(fills []models.Trade) []events.Event {
+	fillEvents := make([]events.Event, 0, 2*len(fills))
+	for i := range fills {
+		for _, userId := range []uint64{fills[i].MakerUserId, fills[i].TakerUserId} {
+			fillEvents = append(fillEvents, events.Event{
+				Type:      events.FILL,
+				Symbol:    fills[i].Symbol,
+				UserId:    userId,
+				Data:      fills[i],
+				Timestamp: fills[i].Timestamp,
+			})
+		}
+	}
+	return fillEvents
+}
+
+// publishUserEvents numbers the events of each user, stores them to be replayed & publishes them.
+// The matching mutex must be locked.
+// Events are published once the state is stored, failing to store them doesn't fail the request.
+func publishUserEvents(ctx context.Context, userEvents []events.Event) {
+	if len(userEvents) == 0 {
+		return
+	}
+	wo := grocksdb.NewDefaultWriteOptions()
+	defer wo.Destroy()
+	batch := grocksdb.NewWriteBatch()
+	defer batch.Destroy()
+
+	sequences := map[string]uint64{}
+	docs := make([]interface{}, len(userEvents))
+	for i := range userEvents {
+		name := userSequenceName(userEvents[i].UserId)
+		seq, ok := sequences[name]
+		if !ok {
+			var err error
+			if seq, err = cachedSequence(name); err != nil {
+				log.Err(err).Uint64("userId", userEvents[i].UserId).Msg("Number user events")
+				return
+			}
+		}
+		seq++
+		sequences[name] = seq
+		rocksdb.SetSequence(batch, name, seq)
+
+		userEvents[i].Sequence = seq
+		docs[i] = storedEvent{
+			UserId:    userEvents[i].UserId,
+			Sequence:  seq,
+			Type:      userEvents[i].Type,
+			Symbol:    userEvents[i].Symbol,
+			Data:      userEvents[i].Data,
+			Timestamp: userEvents[i].Timestamp,
+			CreatedAt: time.Now(),
+		}
+	}
+	if err := rocksdb.DB.Write(wo, batch); err != nil {
+		log.Err(err).Msg("Number user events")
+		return
+	}
+	for name, seq := range sequences {
+		eventSequences[name] = seq
+	}
+	// Live subscribers still get the events which can't be replayed
+	if _, err := mongodb.UserEvent.InsertMany(ctx, docs); err != nil {
+		log.Err(err).Int("count", len(docs)).Msg("Store user events")
+	}
+
+	for i := range userEvents {
+		events.Publish(userEvents[i])
+	}
+}
+
+type UserStreamQuery struct {
+	// Sequence of the last event received, the events after it are replayed
+	After uint64 `query:"after"`
+}
+
+// UserStream is a WebSocket streaming the events of the user's orders & fills.
+// With after, the events following that sequence are replayed first.
+func UserStream(c echo.Context) error {
+	req := UserStreamQuery{}
+	if err := utils.BindNValidate(c, &req); err != nil {
+		return err
+	}
+	replay := c.QueryParams().Has("after")
+	userId := c.Get("userId").(uint64)
+
+	websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		// Subscribed before the replay, so that no event is missed in between
+		eventCh, unsubscribe := events.Subscribe(streamBuffer)
+		defer unsubscribe()
+
+		// Messages of the client are ignored, reading only detects the disconnection
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			io.Copy(io.Discard, ws)
+		}()
+
+		var lastSeq uint64
+		if replay {
+			var err error
+			if lastSeq, err = replayUserEvents(c.Request().Context(), ws, userId, req.After); err != nil {
+				return
+			}
+		}
+		for {
+			select {
+			case <-done:
+				return
+			case event, ok := <-eventCh:
+				if !ok {
+					return
+				}
+				// Market data events are streamed by MarketStream
+				if event.UserId != userId || len(eventChannel(&event)) > 0 || event.Sequence <= lastSeq {
+					continue
+				}
+				if err := websocket.JSON.Send(ws, event); err != nil {
+					return
+				}
+				lastSeq = event.Sequence
+			}
+		}
+	}}.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+// replayUserEvents sends the stored events of the user following the sequence, and returns the sequence of the last one
+func replayUserEvents(ctx context.Context, ws *websocket.Conn, userId uint64, after uint64) (uint64, error) {
+	cursor, err := mongodb.UserEvent.Find(ctx, bson.M{
+		"user_id":  userId,
+		"sequence": bson.M{"$gt": after},
+	}, options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}}))
+	if err != nil {
+		return after, err
+	}
+	defer cursor.Close(ctx)
+
+	lastSeq := after
+	for cursor.Next(ctx) {
+		stored := replayedEvent{}
+		if err := cursor.Decode(&stored); err != nil {
+			return lastSeq, err
+		}
+		if stored.Sequence > lastSeq+1 {
+			// Events are kept for 24 hours
+			gap := events.Event{
+				Type:      STREAM_ERROR,
+				Data:      utils.ErrResponse{Message: "Events after sequence " + strconv.FormatUint(lastSeq, 10) + " are no longer available"},
+				Timestamp: uint64(time.Now().UnixNano()),
+			}
+			if err := websocket.JSON.Send(ws, gap); err != nil {
+				return lastSeq, err
+			}
+		}
+
+		event := events.Event{
+			Type:      stored.Type,
+			Symbol:    stored.Symbol,
+			Sequence:  stored.Sequence,
+			UserId:    stored.UserId,
+			Timestamp: stored.Timestamp,
+		}
+		if stored.Type == events.FILL {
+			fill := models.Trade{}
+			err = bson.Unmarshal(stored.Data, &fill)
+			event.Data = fill
+		} else {
+			order := models.Order{}
+			err = bson.Unmarshal(stored.Data, &order)
+			event.Data = order
+		}
+		if err != nil {
+			return lastSeq, err
+		}
+		if err := websocket.JSON.Send(ws, event); err != nil {
+			return lastSeq, err
+		}
+		lastSeq = stored.Sequence
+	}
+	return lastSeq, cursor.Err()
+}
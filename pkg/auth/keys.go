@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+// Key verifies the signature of tokens of its algorithm
+type Key struct {
+	// Key ID, matched against the kid of the token header
+	ID        string
+	Algorithm string
+	// Secret of HS256 keys
+	Secret []byte
+	// *rsa.PublicKey of RS256 keys, *ecdsa.PublicKey of ES256 keys
+	PublicKey interface{}
+}
+
+// JWK is a JSON Web Key, as listed in a JWKS file
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	// Symmetric
+	K string `json:"k,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+var keysMutex sync.RWMutex
+
+// Keys from JWT_SECRET & JWT_PUBLIC_KEY, or added at runtime
+var staticKeys []Key
+
+// Keys from the JWKS file, reloaded when it changes
+var jwksKeys []Key
+var jwksPath string
+var jwksModTime time.Time
+
+// Expected aud & iss claims, not checked when empty
+var audience string
+var issuer string
+
+// Init loads the verification keys:
+//   - JWT_SECRET: secret of HS256 tokens
+//   - JWT_PUBLIC_KEY: path to a PEM public key (RSA for RS256, P-256 for ES256)
+//   - JWT_JWKS: path to a local JWKS file, reloaded when a token is signed by an unknown key to rotate keys
+//
+// JWT_AUDIENCE & JWT_ISSUER are the expected aud & iss claims.
+func Init() {
+	keysMutex.Lock()
+	defer keysMutex.Unlock()
+
+	staticKeys = make([]Key, 0)
+	if secret := os.Getenv("JWT_SECRET"); len(secret) > 0 {
+		staticKeys = append(staticKeys, Key{Algorithm: HS256, Secret: []byte(secret)})
+	}
+	if path := os.Getenv("JWT_PUBLIC_KEY"); len(path) > 0 {
+		key, err := loadPublicKey(path)
+		if err != nil {
+			panic(err)
+		}
+		staticKeys = append(staticKeys, key)
+	}
+
+	jwksKeys = nil
+	jwksModTime = time.Time{}
+	jwksPath = os.Getenv("JWT_JWKS")
+	if len(jwksPath) > 0 {
+		if err := reloadJWKS(); err != nil {
+			panic(err)
+		}
+	}
+
+	audience = os.Getenv("JWT_AUDIENCE")
+	issuer = os.Getenv("JWT_ISSUER")
+
+	log.Info().Int("keys", len(staticKeys)+len(jwksKeys)).Msg("JWT keys loaded")
+}
+
+// AddKey adds a verification key
+func AddKey(key Key) {
+	keysMutex.Lock()
+	defer keysMutex.Unlock()
+	staticKeys = append(staticKeys, key)
+}
+
+// ExpectedAudience is the expected aud claim, empty when it is not checked
+func ExpectedAudience() string {
+	keysMutex.RLock()
+	defer keysMutex.RUnlock()
+	return audience
+}
+
+// findKeys returns the keys which may have signed a token of the algorithm & key ID.
+// A token without key ID may be signed by any key of its algorithm.
+func findKeys(algorithm string, kid string) []Key {
+	keysMutex.RLock()
+	found := matchKeys(algorithm, kid)
+	reloadable := len(jwksPath) > 0
+	keysMutex.RUnlock()
+	if len(found) > 0 || !reloadable {
+		return found
+	}
+
+	// The key may have been rotated in
+	keysMutex.Lock()
+	defer keysMutex.Unlock()
+	if err := reloadJWKS(); err != nil {
+		log.Err(err).Str("path", jwksPath).Msg("Failed to reload JWKS")
+	}
+	return matchKeys(algorithm, kid)
+}
+
+func matchKeys(algorithm string, kid string) []Key {
+	found := make([]Key, 0, 1)
+	for _, list := range [][]Key{staticKeys, jwksKeys} {
+		for _, key := range list {
+			if key.Algorithm == algorithm && (len(kid) == 0 || key.ID == kid) {
+				found = append(found, key)
+			}
+		}
+	}
+	return found
+}
+
+// reloadJWKS replaces the keys of the JWKS file if it was modified. keysMutex must be locked.
+func reloadJWKS() error {
+	info, err := os.Stat(jwksPath)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(jwksModTime) {
+		return nil
+	}
+	data, err := os.ReadFile(jwksPath)
+	if err != nil {
+		return err
+	}
+	jwks := JWKS{}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return err
+	}
+
+	keys := make([]Key, 0, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if len(jwk.Use) > 0 && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(jwk)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	jwksKeys = keys
+	jwksModTime = info.ModTime()
+	log.Info().Str("path", jwksPath).Int("keys", len(keys)).Msg("JWKS loaded")
+	return nil
+}
+
+func parseJWK(jwk JWK) (Key, error) {
+	key := Key{ID: jwk.Kid, Algorithm: jwk.Alg}
+	switch jwk.Kty {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil || len(secret) == 0 {
+			return key, errors.New("invalid oct key " + jwk.Kid)
+		}
+		key.Secret = secret
+		if len(key.Algorithm) == 0 {
+			key.Algorithm = HS256
+		}
+	case "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		exponent := new(big.Int).SetBytes(e)
+		if errN != nil || errE != nil || len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 {
+			return key, errors.New("invalid RSA key " + jwk.Kid)
+		}
+		key.PublicKey = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+		if len(key.Algorithm) == 0 {
+			key.Algorithm = RS256
+		}
+	case "EC":
+		x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+		y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if jwk.Crv != "P-256" || errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+			return key, errors.New("invalid EC key " + jwk.Kid)
+		}
+		// Rejects points which are not on the curve
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return key, errors.New("invalid EC key " + jwk.Kid)
+		}
+		key.PublicKey = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if len(key.Algorithm) == 0 {
+			key.Algorithm = ES256
+		}
+	default:
+		return key, errors.New("unsupported key type " + jwk.Kty)
+	}
+	if !key.usable() {
+		return key, errors.New("unsupported algorithm " + key.Algorithm + " of key " + jwk.Kid)
+	}
+	return key, nil
+}
+
+func loadPublicKey(path string) (Key, error) {
+	key := Key{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return key, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return key, errors.New("invalid PEM file " + path)
+	}
+	key.PublicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return key, err
+	}
+	switch publicKey := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		key.Algorithm = RS256
+	case *ecdsa.PublicKey:
+		if publicKey.Curve == elliptic.P256() {
+			key.Algorithm = ES256
+		}
+	}
+	if !key.usable() {
+		return key, errors.New("unsupported public key " + path)
+	}
+	return key, nil
+}
+
+// usable reports whether the key material matches the algorithm
+func (k *Key) usable() bool {
+	switch k.Algorithm {
+	case HS256:
+		return len(k.Secret) > 0
+	case RS256:
+		_, ok := k.PublicKey.(*rsa.PublicKey)
+		return ok
+	case ES256:
+		publicKey, ok := k.PublicKey.(*ecdsa.PublicKey)
+		return ok && publicKey.Curve == elliptic.P256()
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Headers of requests signed with an API key
+const (
+	HeaderApiKey       = "X-API-KEY"
+	HeaderApiTimestamp = "X-API-TIMESTAMP"
+	HeaderApiSignature = "X-API-SIGNATURE"
+)
+
+// Sign is the hex HMAC-SHA256 of the timestamp (Unix milliseconds), the method, the request URI (path & query) and the body
+func Sign(secret string, timestamp string, method string, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + method + requestURI))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
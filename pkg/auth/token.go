@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tolerated clock skew when checking exp & nbf
+const Leeway = 30 * time.Second
+
+var (
+	ErrMalformedToken   = errors.New("Malformed token")
+	ErrUnsupportedAlg   = errors.New("Unsupported token algorithm")
+	ErrUnknownKey       = errors.New("Unknown token key")
+	ErrInvalidSignature = errors.New("Invalid token signature")
+	ErrTokenExpired     = errors.New("Token expired")
+	ErrTokenNotValidYet = errors.New("Token not valid yet")
+	ErrInvalidAudience  = errors.New("Invalid token audience")
+	ErrInvalidIssuer    = errors.New("Invalid token issuer")
+	ErrInvalidSubject   = errors.New("Invalid token subject")
+)
+
+type Header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// Audience is the aud claim, a string or a list of strings
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Claims are the registered claims of a token. Times are Unix timestamps in seconds.
+type Claims struct {
+	// User ID, in decimal
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+}
+
+// UserId is the user ID of the subject
+func (c *Claims) UserId() (uint64, error) {
+	userId, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidSubject
+	}
+	return userId, nil
+}
+
+// Verify checks the signature & claims of a compact JWS token, and returns its claims
+func Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+	header := Header{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrMalformedToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	// Rejects "none" & algorithms which could confuse public keys with secrets
+	if header.Alg != HS256 && header.Alg != RS256 && header.Alg != ES256 {
+		return nil, ErrUnsupportedAlg
+	}
+
+	keys := findKeys(header.Alg, header.Kid)
+	if len(keys) == 0 {
+		return nil, ErrUnknownKey
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for i := range keys {
+		if keys[i].verify(signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrInvalidSignature
+	}
+
+	claims := Claims{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformedToken
+	}
+	if err := claims.validate(time.Now()); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+func (c *Claims) validate(now time.Time) error {
+	// exp is required, so that a leaked token can't be used forever
+	if c.ExpiresAt == 0 || now.Add(-Leeway).Unix() >= c.ExpiresAt {
+		return ErrTokenExpired
+	}
+	if c.NotBefore > 0 && now.Add(Leeway).Unix() < c.NotBefore {
+		return ErrTokenNotValidYet
+	}
+	keysMutex.RLock()
+	expectedAudience, expectedIssuer := audience, issuer
+	keysMutex.RUnlock()
+	if len(expectedAudience) > 0 && !c.Audience.contains(expectedAudience) {
+		return ErrInvalidAudience
+	}
+	if len(expectedIssuer) > 0 && c.Issuer != expectedIssuer {
+		return ErrInvalidIssuer
+	}
+	_, err := c.UserId()
+	return err
+}
+
+func (a Audience) contains(value string) bool {
+	for _, aud := range a {
+		if aud == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *Key) verify(signed []byte, signature []byte) bool {
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.Secret)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case RS256:
+		digest := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(k.PublicKey.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	case ES256:
+		// The signature is r & s in 32 bytes each
+		if len(signature) != 64 {
+			return false
+		}
+		digest := sha256.Sum256(signed)
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(k.PublicKey.(*ecdsa.PublicKey), digest[:], r, s)
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
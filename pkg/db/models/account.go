@@ -0,0 +1,8 @@
+package models
+
+// Account holds the trading settings of a user
+type Account struct {
+	UserId uint64 `json:"userId" bson:"user_id"`
+	// Default self-trade prevention mode of the user's orders
+	SelfTradePrevention STPMode `json:"selfTradePrevention,omitempty" bson:"self_trade_prevention,omitempty"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type ApiKeyPermission string
+
+const (
+	// Read orders, trades & account settings
+	READ ApiKeyPermission = "READ"
+	// Place, amend & cancel orders, and update account settings. Trade keys can also read.
+	TRADE ApiKeyPermission = "TRADE"
+)
+
+// ApiKey is a long-lived credential of a user, signing requests with its secret
+type ApiKey struct {
+	ID     primitive.ObjectID `json:"id" bson:"_id"`
+	UserId uint64             `json:"userId" bson:"user_id"`
+	Name   string             `json:"name,omitempty" bson:"name,omitempty"`
+	// Public identifier, sent in the X-API-KEY header
+	Key string `json:"key" bson:"key"`
+	// HMAC-SHA256 secret, only returned on creation
+	Secret      string             `json:"-" bson:"secret"`
+	Permissions []ApiKeyPermission `json:"permissions" bson:"permissions"`
+	CreatedAt   uint64             `json:"createdAt" bson:"created_at"`
+	RevokedAt   uint64             `json:"revokedAt,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// Allows reports whether the key has the permission
+func (k *ApiKey) Allows(permission ApiKeyPermission) bool {
+	for _, p := range k.Permissions {
+		if p == permission || (p == TRADE && permission == READ) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,14 @@
+package models
+
+// Balance holds the funds of a user in an asset. Locked funds back the user's open orders.
+type Balance struct {
+	UserId    uint64  `json:"userId"`
+	Asset     string  `json:"asset"`
+	Available Decimal `json:"available"`
+	Locked    Decimal `json:"locked"`
+}
+
+// Total is the available & locked funds
+func (b *Balance) Total() Decimal {
+	return b.Available.Add(b.Locked)
+}
@@ -0,0 +1,17 @@
+package models
+
+// Candle aggregates the trades of a market during an interval
+type Candle struct {
+	Symbol   string `json:"symbol" bson:"symbol"`
+	Interval string `json:"interval" bson:"interval"`
+	// Start of the interval, as Unix timestamp in microseconds
+	OpenTime    int64   `json:"openTime" bson:"open_time"`
+	Open        Decimal `json:"open" bson:"open"`
+	High        Decimal `json:"high" bson:"high"`
+	Low         Decimal `json:"low" bson:"low"`
+	Close       Decimal `json:"close" bson:"close"`
+	Volume      Decimal `json:"volume" bson:"volume"`
+	QuoteVolume Decimal `json:"quoteVolume" bson:"quote_volume"`
+	// Number of trades
+	Count int64 `json:"count" bson:"count"`
+}
@@ -0,0 +1,267 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Number of decimal places of a Decimal
+const DECIMALS = 18
+
+var wei18 = new(big.Int).Exp(big.NewInt(10), big.NewInt(DECIMALS), nil)
+
+// Max value of a Decimal stored in 16 bytes
+var maxWei = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+// Max significant digits of a Decimal stored as a Decimal128 in MongoDB
+const MaxSignificantDigits = 34
+
+var ten = big.NewInt(10)
+
+// Decimal is an exact fixed-point number with 18 decimal places, kept as an integer in Wei unit.
+// It is a JSON string in API payloads and a Decimal128 in MongoDB. The zero value is 0.
+type Decimal struct {
+	wei *big.Int
+}
+
+func ParseDecimal(s string) (Decimal, error) {
+	str := s
+	neg := false
+	if strings.HasPrefix(str, "-") {
+		neg = true
+		str = str[1:]
+	}
+	intPart, fracPart, hasPoint := strings.Cut(str, ".")
+	if len(intPart) == 0 || (hasPoint && len(fracPart) == 0) {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	if len(fracPart) > DECIMALS {
+		return Decimal{}, fmt.Errorf("decimal %q has more than %d decimal places", s, DECIMALS)
+	}
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+		}
+	}
+
+	wei, _ := new(big.Int).SetString(intPart+fracPart+strings.Repeat("0", DECIMALS-len(fracPart)), 10)
+	if neg {
+		wei.Neg(wei)
+	}
+	return Decimal{wei: wei}, nil
+}
+
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func NewDecimalFromInt(i int64) Decimal {
+	return Decimal{wei: new(big.Int).Mul(big.NewInt(i), wei18)}
+}
+
+// DecimalFromBytes reads a Decimal written by Bytes16
+func DecimalFromBytes(b []byte) Decimal {
+	return Decimal{wei: new(big.Int).SetBytes(b)}
+}
+
+func (d Decimal) int() *big.Int {
+	if d.wei == nil {
+		return new(big.Int)
+	}
+	return d.wei
+}
+
+// Bytes16 returns the Wei value as a 16-byte big-endian unsigned integer, which keeps the byte order of values.
+// It fails if the value is negative or doesn't fit in 128 bits.
+func (d Decimal) Bytes16() ([]byte, error) {
+	wei := d.int()
+	if wei.Sign() < 0 || wei.Cmp(maxWei) > 0 {
+		return nil, fmt.Errorf("decimal %s out of 128-bit range", d)
+	}
+	return wei.FillBytes(make([]byte, 16)), nil
+}
+
+func (d Decimal) Add(other Decimal) Decimal {
+	return Decimal{wei: new(big.Int).Add(d.int(), other.int())}
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	return Decimal{wei: new(big.Int).Sub(d.int(), other.int())}
+}
+
+// Mul returns the product, truncated to 18 decimal places
+func (d Decimal) Mul(other Decimal) Decimal {
+	wei := new(big.Int).Mul(d.int(), other.int())
+	return Decimal{wei: wei.Quo(wei, wei18)}
+}
+
+// Quo returns the quotient, truncated to 18 decimal places. other must not be zero.
+func (d Decimal) Quo(other Decimal) Decimal {
+	wei := new(big.Int).Mul(d.int(), wei18)
+	return Decimal{wei: wei.Quo(wei, other.int())}
+}
+
+// Mod returns the remainder of d divided by other, which must not be zero
+func (d Decimal) Mod(other Decimal) Decimal {
+	return Decimal{wei: new(big.Int).Rem(d.int(), other.int())}
+}
+
+func (d Decimal) Abs() Decimal {
+	return Decimal{wei: new(big.Int).Abs(d.int())}
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{wei: new(big.Int).Neg(d.int())}
+}
+
+func (d Decimal) Cmp(other Decimal) int {
+	return d.int().Cmp(other.int())
+}
+
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+func MinDecimal(a, b Decimal) Decimal {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+func MaxDecimal(a, b Decimal) Decimal {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+func (d Decimal) String() string {
+	wei := d.int()
+	abs := new(big.Int).Abs(wei)
+	intPart, fracPart := new(big.Int).QuoRem(abs, wei18, new(big.Int))
+
+	str := intPart.String()
+	if fracPart.Sign() > 0 {
+		frac := fmt.Sprintf("%0*s", DECIMALS, fracPart.String())
+		str += "." + strings.TrimRight(frac, "0")
+	}
+	if wei.Sign() < 0 {
+		str = "-" + str
+	}
+	return str
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts a JSON string, or a JSON number which is read from its literal text
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if str == "null" {
+		return nil
+	}
+	if strings.HasPrefix(str, `"`) {
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+	}
+	parsed, err := ParseDecimal(str)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// UnmarshalText allows a Decimal to be bound from query & path params
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Decimal128 converts the decimal, which fails beyond the 34 significant digits of a Decimal128.
+// Trailing zeros are dropped, as when parsing the string of the decimal.
+func (d Decimal) Decimal128() (primitive.Decimal128, bool) {
+	coef := new(big.Int).Set(d.int())
+	exp := -DECIMALS
+	if coef.Sign() == 0 {
+		exp = 0
+	}
+	quo, rem := new(big.Int), new(big.Int)
+	for exp < 0 {
+		quo.QuoRem(coef, ten, rem)
+		if rem.Sign() != 0 {
+			break
+		}
+		coef.Set(quo)
+		exp++
+	}
+	return primitive.ParseDecimal128FromBigInt(coef, exp)
+}
+
+func (d Decimal) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	d128, ok := d.Decimal128()
+	if !ok {
+		return 0, nil, fmt.Errorf("decimal %s has more than %d significant digits", d, MaxSignificantDigits)
+	}
+	return bson.MarshalValue(d128)
+}
+
+func (d *Decimal) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.Decimal128:
+		coef, exp, err := value.Decimal128().BigInt()
+		if err != nil {
+			return err
+		}
+		// Scale coef * 10^exp to Wei unit
+		scale := exp + DECIMALS
+		if scale >= 0 {
+			coef.Mul(coef, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil))
+		} else {
+			rem := new(big.Int)
+			coef.QuoRem(coef, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-scale)), nil), rem)
+			if rem.Sign() != 0 {
+				return fmt.Errorf("decimal %s has more than %d decimal places", value.Decimal128(), DECIMALS)
+			}
+		}
+		*d = Decimal{wei: coef}
+		return nil
+	case bsontype.String:
+		parsed, err := ParseDecimal(value.StringValue())
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	case bsontype.Null:
+		return nil
+	}
+	return errors.New("decimal must be stored as a Decimal128 or a string")
+}
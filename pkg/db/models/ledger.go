@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+type LedgerEntryType string
+
+const (
+	// Exchange of the base & quote assets of a trade
+	FILL LedgerEntryType = "FILL"
+	// Fee of a trade, paid in the asset received
+	FEE        LedgerEntryType = "FEE"
+	DEPOSIT    LedgerEntryType = "DEPOSIT"
+	WITHDRAWAL LedgerEntryType = "WITHDRAWAL"
+)
+
+// LedgerAccount is an account of the ledger: the funds of a user, or a system account
+type LedgerAccount string
+
+const (
+	// Fees collected by the exchange
+	FEES_ACCOUNT LedgerAccount = "fees"
+	// Funds outside of the exchange, the counterpart of deposits & withdrawals
+	EXTERNAL_ACCOUNT LedgerAccount = "external"
+)
+
+const userAccountPrefix = "user:"
+
+func UserAccount(userId uint64) LedgerAccount {
+	return LedgerAccount(userAccountPrefix + strconv.FormatUint(userId, 10))
+}
+
+// UserId returns the user of the account, if it is the account of a user
+func (a LedgerAccount) UserId() (uint64, bool) {
+	id, ok := strings.CutPrefix(string(a), userAccountPrefix)
+	if !ok {
+		return 0, false
+	}
+	userId, err := strconv.ParseUint(id, 10, 64)
+	return userId, err == nil
+}
+
+// LedgerEntry is an immutable entry of the journal, either a debit or a credit of an account in an asset.
+// A credit increases the funds of a user, a debit decreases them.
+type LedgerEntry struct {
+	Sequence uint64 `json:"sequence"`
+	// Sequence of the first entry of the transaction. The debits & credits of a transaction balance in each asset.
+	TransactionId uint64          `json:"transactionId"`
+	Type          LedgerEntryType `json:"type"`
+	Account       LedgerAccount   `json:"account"`
+	Asset         string          `json:"asset"`
+	Debit         Decimal         `json:"debit"`
+	Credit        Decimal         `json:"credit"`
+	// Trade of a fill or fee
+	Symbol        string `json:"symbol,omitempty"`
+	TradeSequence uint64 `json:"tradeSequence,omitempty"`
+	Timestamp     uint64 `json:"timestamp"`
+}
+
+// Amount returns the change of the account funds
+func (e *LedgerEntry) Amount() Decimal {
+	return e.Credit.Sub(e.Debit)
+}
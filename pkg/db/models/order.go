@@ -3,7 +3,6 @@ package models
 import (
 	"encoding/base32"
 	"encoding/binary"
-	"math/big"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -15,21 +14,128 @@ const (
 	SELL OrderType = "SELL"
 )
 
+type OrderKind string
+
+const (
+	LIMIT  OrderKind = "LIMIT"
+	MARKET OrderKind = "MARKET"
+)
+
+type TimeInForce string
+
+const (
+	// Good till cancelled
+	GTC TimeInForce = "GTC"
+	// Immediate or cancel: fill what can be filled, cancel the rest
+	IOC TimeInForce = "IOC"
+	// Fill or kill: fill the whole quantity or nothing
+	FOK TimeInForce = "FOK"
+	// Rejected if it would take liquidity
+	POST_ONLY TimeInForce = "POST_ONLY"
+	// Good till date
+	GTD TimeInForce = "GTD"
+)
+
+// STPMode is the self-trade prevention mode, applied when an order would match an order of the same user
+type STPMode string
+
+const (
+	// Cancel the incoming order
+	CANCEL_NEWEST STPMode = "CANCEL_NEWEST"
+	// Cancel the resting order, the incoming order keeps matching
+	CANCEL_OLDEST STPMode = "CANCEL_OLDEST"
+	// Cancel both orders
+	CANCEL_BOTH STPMode = "CANCEL_BOTH"
+	// Decrease both orders by the smaller quantity, which cancels the smaller one
+	DECREMENT_AND_CANCEL STPMode = "DECREMENT_AND_CANCEL"
+	// Match orders of the same user as usual
+	ALLOW_SELF_TRADE STPMode = "ALLOW"
+)
+
+// Mode used when neither the order nor the account sets one
+const DEFAULT_STP_MODE = CANCEL_NEWEST
+
+type OrderStatus string
+
+const (
+	NEW              OrderStatus = "NEW"
+	PARTIALLY_FILLED OrderStatus = "PARTIALLY_FILLED"
+	FILLED           OrderStatus = "FILLED"
+	CANCELLED        OrderStatus = "CANCELLED"
+	EXPIRED          OrderStatus = "EXPIRED"
+	REJECTED         OrderStatus = "REJECTED"
+)
+
+// Statuses of orders resting on the book
+var OpenStatuses = []OrderStatus{NEW, PARTIALLY_FILLED}
+
+type StatusTransition struct {
+	Status    OrderStatus `json:"status" bson:"status"`
+	Timestamp uint64      `json:"timestamp" bson:"timestamp"`
+}
+
 type Order struct {
-	ID        *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	UserId    uint64              `json:"userId" bson:"user_id"`
-	Type      OrderType           `json:"type" bson:"type"`
-	Price     float64             `json:"price" bson:"price"`
-	ExpiredAt *uint64             `json:"expiredAt,omitempty" bson:"expired_at,omitempty"`
-	Timestamp uint64              `json:"timestamp,omitempty" bson:"timestamp,omitempty"`
-	Key       string              `json:"key,omitempty" bson:"key,omitempty"`
+	ID            *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	UserId        uint64              `json:"userId" bson:"user_id"`
+	ClientOrderId string              `json:"clientOrderId,omitempty" bson:"client_order_id,omitempty"`
+	Symbol        string              `json:"symbol" bson:"symbol"`
+	Type          OrderType           `json:"type" bson:"type"`
+	OrderType     OrderKind           `json:"orderType" bson:"order_type"`
+	TimeInForce   TimeInForce         `json:"timeInForce" bson:"time_in_force"`
+	Price         Decimal             `json:"price" bson:"price"`
+	Quantity      Decimal             `json:"quantity" bson:"quantity"`
+	Remaining     Decimal             `json:"remaining" bson:"remaining"`
+	ExpiredAt     *uint64             `json:"expiredAt,omitempty" bson:"expired_at,omitempty"`
+	CreatedAt     uint64              `json:"createdAt,omitempty" bson:"created_at,omitempty"`
+	Timestamp     uint64              `json:"timestamp,omitempty" bson:"timestamp,omitempty"`
+	Key           string              `json:"key,omitempty" bson:"key,omitempty"`
+	Version       uint64              `json:"version" bson:"version"`
+
+	// Self-trade prevention mode of the order & the action applied to it, if any
+	SelfTradePrevention STPMode `json:"selfTradePrevention" bson:"self_trade_prevention"`
+	SelfTradeAction     STPMode `json:"selfTradeAction,omitempty" bson:"self_trade_action,omitempty"`
+
+	Status      OrderStatus        `json:"status,omitempty" bson:"status,omitempty"`
+	Transitions []StatusTransition `json:"transitions,omitempty" bson:"transitions,omitempty"`
+}
+
+// SetStatus records the status transition, if the status changes
+func (order *Order) SetStatus(status OrderStatus, ts uint64) {
+	if order.Status == status {
+		return
+	}
+	order.Status = status
+	order.Transitions = append(order.Transitions, StatusTransition{Status: status, Timestamp: ts})
+}
+
+// IsOpen reports whether the order is resting on the book
+func (order *Order) IsOpen() bool {
+	return order.Status == NEW || order.Status == PARTIALLY_FILLED
+}
+
+// IsExpired reports whether the order has passed its expiration time at ts
+func (order *Order) IsExpired(ts uint64) bool {
+	return order.ExpiredAt != nil && *order.ExpiredAt > 0 && ts > *order.ExpiredAt
+}
+
+// CanRest reports whether the unfilled remainder of the order may rest on the book
+func (order *Order) CanRest() bool {
+	return order.OrderType != MARKET && order.TimeInForce != IOC && order.TimeInForce != FOK
+}
+
+// FillStatus returns the status of the order according to its remaining quantity
+func (order *Order) FillStatus() OrderStatus {
+	if order.Remaining.Sign() <= 0 {
+		return FILLED
+	}
+	if order.Remaining.Cmp(order.Quantity) < 0 {
+		return PARTIALLY_FILLED
+	}
+	return NEW
 }
 
 func (order *Order) ParseKV(key []byte, value []byte) {
-	price := new(big.Int).SetBytes(key[:16])
-	priceFloat := big.NewFloat(0).SetInt(price)
-	priceFloat.Quo(priceFloat, big.NewFloat(WEI18))
-	order.Price, _ = priceFloat.Float64()
+	order.Price = DecimalFromBytes(key[:16])
 
 	ts := binary.BigEndian.Uint64(key[16:24])
 	if order.Type == BUY {
@@ -42,18 +148,21 @@ func (order *Order) ParseKV(key []byte, value []byte) {
 		exp := binary.BigEndian.Uint64(value)
 		order.ExpiredAt = &exp
 	}
+	if len(value) >= 24 {
+		order.Remaining = DecimalFromBytes(value[8:24])
+	}
 
 	order.Key = base32.StdEncoding.EncodeToString(key)
 }
 
+// ToKVBytes encodes the order for the order book.
+// Price & remaining quantity must fit in 128 bits, which is checked by the API validators.
 func (order *Order) ToKVBytes() ([]byte, []byte) {
 	// 16 bytes for price, 8 bytes for timestamp, 8 bytes for user ID
 	key := make([]byte, 32)
 
-	rawPrice := big.NewFloat(order.Price)
-	priceInt := big.NewInt(0)
-	rawPrice.Mul(rawPrice, big.NewFloat(WEI18)).Int(priceInt)
-	copy(key[16-len(priceInt.Bytes()):], priceInt.Bytes())
+	priceBytes, _ := order.Price.Bytes16()
+	copy(key[:16], priceBytes)
 
 	ts := order.Timestamp
 	if order.Type == BUY {
@@ -68,12 +177,14 @@ func (order *Order) ToKVBytes() ([]byte, []byte) {
 	binary.BigEndian.PutUint64(userIdBytes, order.UserId)
 	copy(key[24:32], userIdBytes)
 
-	value := make([]byte, 8)
+	// 8 bytes for expiration time, 16 bytes for remaining quantity
+	value := make([]byte, 24)
 	if order.ExpiredAt != nil {
 		binary.BigEndian.PutUint64(value, *order.ExpiredAt)
 	}
+	remainingBytes, _ := order.Remaining.Bytes16()
+	copy(value[8:24], remainingBytes)
+
 	order.Key = base32.StdEncoding.EncodeToString(key)
 	return key, value
 }
-
-const WEI18 = 1e18
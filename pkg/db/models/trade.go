@@ -0,0 +1,21 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Trade is an execution between a resting (maker) order and an incoming (taker) order
+type Trade struct {
+	ID           *primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Symbol       string              `json:"symbol" bson:"symbol"`
+	Sequence     uint64              `json:"sequence" bson:"sequence"`
+	MakerOrderId primitive.ObjectID  `json:"makerOrderId" bson:"maker_order_id"`
+	TakerOrderId primitive.ObjectID  `json:"takerOrderId" bson:"taker_order_id"`
+	MakerUserId  uint64              `json:"-" bson:"maker_user_id"`
+	TakerUserId  uint64              `json:"-" bson:"taker_user_id"`
+	Price        Decimal             `json:"price" bson:"price"`
+	Quantity     Decimal             `json:"quantity" bson:"quantity"`
+	// Side of the taker order
+	Side      OrderType `json:"side" bson:"side"`
+	Timestamp uint64    `json:"timestamp" bson:"timestamp"`
+}
@@ -13,6 +13,12 @@ import (
 )
 
 var Order *mongo.Collection
+var Trade *mongo.Collection
+var Account *mongo.Collection
+var PlacedOrder *mongo.Collection
+var Candle *mongo.Collection
+var UserEvent *mongo.Collection
+var ApiKey *mongo.Collection
 var Raw *mongo.Database
 
 func Init() {
@@ -42,10 +48,84 @@ func Init() {
 
 	Raw = client.Database(dbName)
 	Order = Raw.Collection("orders")
+	Trade = Raw.Collection("trades")
+	Account = Raw.Collection("accounts")
+	PlacedOrder = Raw.Collection("placed_orders")
+	Candle = Raw.Collection("candles")
+	UserEvent = Raw.Collection("user_events")
+	ApiKey = Raw.Collection("api_keys")
 
 	bgCtx := context.Background()
 	Order.Indexes().CreateOne(bgCtx, mongo.IndexModel{
-		Keys: bson.D{{Key: "user_id", Value: 1}},
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+	})
+	Order.Indexes().CreateOne(bgCtx, mongo.IndexModel{
+		Keys: bson.D{{Key: "key", Value: 1}},
+	})
+	Order.Indexes().CreateOne(bgCtx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}},
+	})
+	Order.Indexes().CreateOne(bgCtx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "client_order_id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+			"client_order_id": bson.M{"$type": "string"},
+		}),
+	})
+	Trade.Indexes().CreateMany(bgCtx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "symbol", Value: 1}, {Key: "sequence", Value: -1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "maker_user_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "taker_user_id", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "maker_order_id", Value: 1}}},
+		{Keys: bson.D{{Key: "taker_order_id", Value: 1}}},
+	})
+	Account.Indexes().CreateOne(bgCtx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	// Results of placed orders are kept for 24 hours, to answer retried requests
+	PlacedOrder.Indexes().CreateMany(bgCtx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "idempotency_key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+				"idempotency_key": bson.M{"$type": "string"},
+			}),
+		},
+		{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "client_order_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+				"client_order_id": bson.M{"$type": "string"},
+			}),
+		},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32((24 * time.Hour).Seconds())),
+		},
+	})
+	Candle.Indexes().CreateOne(bgCtx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "symbol", Value: 1}, {Key: "interval", Value: 1}, {Key: "open_time", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	// Events of users are kept for 24 hours, to be replayed after a reconnection
+	UserEvent.Indexes().CreateMany(bgCtx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "sequence", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32((24 * time.Hour).Seconds())),
+		},
+	})
+	ApiKey.Indexes().CreateMany(bgCtx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
 	})
 
 	log.Info().Msg("MongoDB connected")
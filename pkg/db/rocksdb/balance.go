@@ -0,0 +1,88 @@
+package rocksdb
+
+import (
+	"encoding/binary"
+	"trading-bsx/pkg/db/models"
+
+	"github.com/linxGnu/grocksdb"
+)
+
+// BalanceKey returns the key of a balance: 8 bytes for user ID & the asset, so that the balances of a user are contiguous
+func BalanceKey(userId uint64, asset string) []byte {
+	key := make([]byte, 8, 8+len(asset))
+	binary.BigEndian.PutUint64(key, userId)
+	return append(key, asset...)
+}
+
+// GetBalance reads the balance of a user in an asset. A missing balance is empty.
+func GetBalance(userId uint64, asset string) (models.Balance, error) {
+	ro := grocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+
+	balance := models.Balance{UserId: userId, Asset: asset}
+	value, err := DB.GetCF(ro, Balances, BalanceKey(userId, asset))
+	if err != nil {
+		return balance, err
+	}
+	defer value.Free()
+	readBalanceValue(&balance, value.Data())
+	return balance, nil
+}
+
+// UserBalances reads every balance of a user, sorted by asset
+func UserBalances(userId uint64) ([]models.Balance, error) {
+	ro := grocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+	it := DB.NewIteratorCF(ro, Balances)
+	defer it.Close()
+
+	prefix := BalanceKey(userId, "")
+	balances := make([]models.Balance, 0)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		key, value := it.Key(), it.Value()
+		balance := models.Balance{UserId: userId, Asset: string(key.Data()[8:])}
+		readBalanceValue(&balance, value.Data())
+		balances = append(balances, balance)
+	}
+	return balances, it.Err()
+}
+
+// IterateBalances calls fn with every balance, until it returns an error
+func IterateBalances(fn func(balance *models.Balance) error) error {
+	ro := grocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+	it := DB.NewIteratorCF(ro, Balances)
+	defer it.Close()
+
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		key := it.Key().Data()
+		balance := models.Balance{UserId: binary.BigEndian.Uint64(key[:8]), Asset: string(key[8:])}
+		readBalanceValue(&balance, it.Value().Data())
+		if err := fn(&balance); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// PutBalance writes a balance with the batch: 16 bytes for available & 16 bytes for locked funds, in Wei unit
+func PutBalance(batch *grocksdb.WriteBatch, balance *models.Balance) error {
+	available, err := balance.Available.Bytes16()
+	if err != nil {
+		return err
+	}
+	locked, err := balance.Locked.Bytes16()
+	if err != nil {
+		return err
+	}
+	batch.PutCF(Balances, BalanceKey(balance.UserId, balance.Asset), append(available, locked...))
+	return nil
+}
+
+func readBalanceValue(balance *models.Balance, value []byte) {
+	if len(value) < 32 {
+		return
+	}
+	balance.Available = models.DecimalFromBytes(value[:16])
+	balance.Locked = models.DecimalFromBytes(value[16:32])
+}
@@ -0,0 +1,45 @@
+package rocksdb
+
+import (
+	"encoding/binary"
+	"trading-bsx/pkg/db/models"
+
+	"github.com/linxGnu/grocksdb"
+)
+
+// ExpiryKey returns the key of an order in the expiry index: 8 bytes for expiration time, 1 byte for side & the 32-byte order key.
+// Keys are sorted by expiration time, so the first key is the next order to expire.
+func ExpiryKey(expiredAt uint64, side models.OrderType, orderKey []byte) []byte {
+	key := make([]byte, 9, 9+len(orderKey))
+	binary.BigEndian.PutUint64(key, expiredAt)
+	if side == models.SELL {
+		key[8] = 1
+	}
+	return append(key, orderKey...)
+}
+
+// ParseExpiryKey reads a key written by ExpiryKey
+func ParseExpiryKey(key []byte) (uint64, models.OrderType, []byte) {
+	side := models.BUY
+	if key[8] == 1 {
+		side = models.SELL
+	}
+	return binary.BigEndian.Uint64(key[:8]), side, key[9:]
+}
+
+// Put writes a resting order with the batch, and indexes its expiration time if it has one
+func (b *OrderBook) Put(batch *grocksdb.WriteBatch, order *models.Order) {
+	key, value := order.ToKVBytes()
+	batch.PutCF(b.Side(order.Type), key, value)
+	if order.ExpiredAt != nil && *order.ExpiredAt > 0 {
+		batch.PutCF(b.Expiry, ExpiryKey(*order.ExpiredAt, order.Type, key), nil)
+	}
+}
+
+// Delete removes a resting order with the batch, together with its expiry index entry
+func (b *OrderBook) Delete(batch *grocksdb.WriteBatch, order *models.Order, key []byte) {
+	batch.DeleteCF(b.Side(order.Type), key)
+	if order.ExpiredAt != nil && *order.ExpiredAt > 0 {
+		batch.DeleteCF(b.Expiry, ExpiryKey(*order.ExpiredAt, order.Type, key))
+	}
+}
@@ -3,15 +3,44 @@ package rocksdb
 import (
 	"fmt"
 	"os"
+	"slices"
 	"time"
+	"trading-bsx/pkg/db/models"
 
 	"github.com/linxGnu/grocksdb"
 )
 
-var BuyOrder *grocksdb.DB
-var SellOrder *grocksdb.DB
+// OrderBook holds the column families storing buy & sell orders of a market, and the expiry index of its orders
+type OrderBook struct {
+	BuyOrder  *grocksdb.ColumnFamilyHandle
+	SellOrder *grocksdb.ColumnFamilyHandle
+	Expiry    *grocksdb.ColumnFamilyHandle
+}
+
+// Side returns the column family storing orders of the given type
+func (b *OrderBook) Side(orderType models.OrderType) *grocksdb.ColumnFamilyHandle {
+	if orderType == models.BUY {
+		return b.BuyOrder
+	}
+	return b.SellOrder
+}
+
+var DB *grocksdb.DB
+var books = map[string]*OrderBook{}
+
+// Balances stores the balances of users in every asset
+var Balances *grocksdb.ColumnFamilyHandle
 
-func Init() {
+// Ledger stores the journal of balance changes by sequence, and UserLedger indexes the entries of each user
+var Ledger, UserLedger *grocksdb.ColumnFamilyHandle
+
+const (
+	balancesCF   = "balances"
+	ledgerCF     = "ledger"
+	userLedgerCF = "user_ledger"
+)
+
+func Init(symbols []string) {
 	cwd, _ := os.Getwd()
 
 	bookName := ""
@@ -19,10 +48,8 @@ func Init() {
 		bookName = fmt.Sprintf("test_%d_", time.Now().UnixMilli())
 	}
 
-	buyOrderPath := fmt.Sprintf("%s/rocksdb_data/%sbuy_order", cwd, bookName)
-	sellOrderPath := fmt.Sprintf("%s/rocksdb_data/%ssell_order", cwd, bookName)
-	os.MkdirAll(buyOrderPath, os.ModePerm)
-	os.MkdirAll(sellOrderPath, os.ModePerm)
+	orderBookPath := fmt.Sprintf("%s/rocksdb_data/%sorder_book", cwd, bookName)
+	os.MkdirAll(orderBookPath, os.ModePerm)
 
 	bbto := grocksdb.NewDefaultBlockBasedTableOptions()
 	bbto.SetBlockCache(grocksdb.NewLRUCache(3 << 30))
@@ -30,14 +57,62 @@ func Init() {
 	opts := grocksdb.NewDefaultOptions()
 	opts.SetBlockBasedTableFactory(bbto)
 	opts.SetCreateIfMissing(true)
+	opts.SetCreateIfMissingColumnFamilies(true)
 
-	var err error
-	BuyOrder, err = grocksdb.OpenDb(opts, buyOrderPath)
+	// Every existing column family must be opened, even if its market is no longer listed
+	cfNames, err := grocksdb.ListColumnFamilies(opts, orderBookPath)
 	if err != nil {
-		panic(err)
+		cfNames = []string{"default"}
+	}
+	for _, cfName := range []string{balancesCF, ledgerCF, userLedgerCF} {
+		if !slices.Contains(cfNames, cfName) {
+			cfNames = append(cfNames, cfName)
+		}
+	}
+	for _, symbol := range symbols {
+		for _, cfName := range []string{buyOrderCF(symbol), sellOrderCF(symbol), expiryCF(symbol)} {
+			if !slices.Contains(cfNames, cfName) {
+				cfNames = append(cfNames, cfName)
+			}
+		}
 	}
-	SellOrder, err = grocksdb.OpenDb(opts, sellOrderPath)
+	cfOpts := make([]*grocksdb.Options, len(cfNames))
+	for i := range cfOpts {
+		cfOpts[i] = opts
+	}
+
+	var cfHandles []*grocksdb.ColumnFamilyHandle
+	DB, cfHandles, err = grocksdb.OpenDbColumnFamilies(opts, orderBookPath, cfNames, cfOpts)
 	if err != nil {
 		panic(err)
 	}
+
+	Balances = cfHandles[slices.Index(cfNames, balancesCF)]
+	Ledger = cfHandles[slices.Index(cfNames, ledgerCF)]
+	UserLedger = cfHandles[slices.Index(cfNames, userLedgerCF)]
+	books = map[string]*OrderBook{}
+	for _, symbol := range symbols {
+		books[symbol] = &OrderBook{
+			BuyOrder:  cfHandles[slices.Index(cfNames, buyOrderCF(symbol))],
+			SellOrder: cfHandles[slices.Index(cfNames, sellOrderCF(symbol))],
+			Expiry:    cfHandles[slices.Index(cfNames, expiryCF(symbol))],
+		}
+	}
+}
+
+// Book returns the order book of a listed market, or nil if the market is not listed
+func Book(symbol string) *OrderBook {
+	return books[symbol]
+}
+
+func buyOrderCF(symbol string) string {
+	return symbol + "/buy_order"
+}
+
+func sellOrderCF(symbol string) string {
+	return symbol + "/sell_order"
+}
+
+func expiryCF(symbol string) string {
+	return symbol + "/expiry"
 }
@@ -0,0 +1,105 @@
+package rocksdb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"trading-bsx/pkg/db/models"
+
+	"github.com/linxGnu/grocksdb"
+)
+
+const ledgerSequenceName = "ledger"
+
+// LastLedgerSequence returns the sequence of the last entry of the journal
+func LastLedgerSequence() (uint64, error) {
+	return LastSequence(ledgerSequenceName)
+}
+
+// PutLedgerEntries appends entries to the journal with the batch, indexed by user. Their sequences follow the last one.
+func PutLedgerEntries(batch *grocksdb.WriteBatch, entries []models.LedgerEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	for i := range entries {
+		value, err := json.Marshal(&entries[i])
+		if err != nil {
+			return err
+		}
+		key := ledgerKey(entries[i].Sequence)
+		batch.PutCF(Ledger, key, value)
+		if userId, ok := entries[i].Account.UserId(); ok {
+			batch.PutCF(UserLedger, userLedgerKey(userId, entries[i].Sequence), nil)
+		}
+	}
+	SetSequence(batch, ledgerSequenceName, entries[len(entries)-1].Sequence)
+	return nil
+}
+
+// UserLedgerEntries reads up to limit entries of a user before the sequence after (all of them if 0), latest first.
+// An asset filters the entries.
+func UserLedgerEntries(userId uint64, asset string, after uint64, limit int) ([]models.LedgerEntry, error) {
+	ro := grocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+	it := DB.NewIteratorCF(ro, UserLedger)
+	defer it.Close()
+
+	prefix := userLedgerKey(userId, 0)[:8]
+	if after == 0 {
+		it.SeekForPrev(userLedgerKey(userId, ^uint64(0)))
+	} else {
+		it.SeekForPrev(userLedgerKey(userId, after-1))
+	}
+	entries := make([]models.LedgerEntry, 0)
+	for ; it.ValidForPrefix(prefix) && len(entries) < limit; it.Prev() {
+		sequence := binary.BigEndian.Uint64(it.Key().Data()[8:])
+		entry, err := getLedgerEntry(ro, sequence)
+		if err != nil {
+			return nil, err
+		}
+		if len(asset) > 0 && entry.Asset != asset {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, it.Err()
+}
+
+// IterateLedger calls fn with every entry of the journal, in sequence order, until it returns an error
+func IterateLedger(fn func(entry *models.LedgerEntry) error) error {
+	ro := grocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+	it := DB.NewIteratorCF(ro, Ledger)
+	defer it.Close()
+
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		entry := models.LedgerEntry{}
+		if err := json.Unmarshal(it.Value().Data(), &entry); err != nil {
+			return err
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+func getLedgerEntry(ro *grocksdb.ReadOptions, sequence uint64) (models.LedgerEntry, error) {
+	entry := models.LedgerEntry{}
+	value, err := DB.GetCF(ro, Ledger, ledgerKey(sequence))
+	if err != nil {
+		return entry, err
+	}
+	defer value.Free()
+	err = json.Unmarshal(value.Data(), &entry)
+	return entry, err
+}
+
+func ledgerKey(sequence uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, sequence)
+}
+
+// userLedgerKey is 8 bytes for user ID & 8 bytes for the sequence of the entry
+func userLedgerKey(userId uint64, sequence uint64) []byte {
+	key := binary.BigEndian.AppendUint64(make([]byte, 0, 16), userId)
+	return binary.BigEndian.AppendUint64(key, sequence)
+}
@@ -0,0 +1,33 @@
+package rocksdb
+
+import (
+	"encoding/binary"
+
+	"github.com/linxGnu/grocksdb"
+)
+
+// LastSequence returns the last value of a counter stored in the default column family
+func LastSequence(name string) (uint64, error) {
+	ro := grocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+
+	value, err := DB.GetBytes(ro, sequenceKey(name))
+	if err != nil {
+		return 0, err
+	}
+	if len(value) < 8 {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint64(value), nil
+}
+
+// SetSequence writes a counter with the batch, so it is updated atomically with the orders
+func SetSequence(batch *grocksdb.WriteBatch, name string, seq uint64) {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, seq)
+	batch.Put(sequenceKey(name), value)
+}
+
+func sequenceKey(name string) []byte {
+	return []byte("sequence/" + name)
+}
@@ -0,0 +1,70 @@
+package events
+
+import (
+	"sync"
+)
+
+type EventType string
+
+const (
+	// Private events of a user, about their orders
+	ORDER_ACCEPTED         EventType = "ORDER_ACCEPTED"
+	ORDER_PARTIALLY_FILLED EventType = "ORDER_PARTIALLY_FILLED"
+	ORDER_FILLED           EventType = "ORDER_FILLED"
+	ORDER_CANCELLED        EventType = "ORDER_CANCELLED"
+	ORDER_EXPIRED          EventType = "ORDER_EXPIRED"
+	ORDER_REJECTED         EventType = "ORDER_REJECTED"
+	ORDER_AMENDED          EventType = "ORDER_AMENDED"
+	FILL                   EventType = "FILL"
+
+	// Market data
+	TRADE       EventType = "TRADE"
+	BOOK_UPDATE EventType = "BOOK_UPDATE"
+	TICKER      EventType = "TICKER"
+	CANDLE      EventType = "CANDLE"
+)
+
+// Event is a change in the trading engine, published to every subscriber
+type Event struct {
+	Type   EventType `json:"type"`
+	Symbol string    `json:"symbol"`
+	// Position of the event in its stream, for consumers to detect missed events
+	Sequence uint64 `json:"sequence,omitempty"`
+	// Owner of the order, for private events
+	UserId    uint64      `json:"-"`
+	Data      interface{} `json:"data"`
+	Timestamp uint64      `json:"timestamp"`
+}
+
+var mutex = sync.RWMutex{}
+var subscribers = map[chan Event]struct{}{}
+
+// Subscribe returns a channel receiving every published event, and a function to unsubscribe.
+// Events are dropped for a subscriber whose buffer is full, so that publishers never block.
+func Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+	mutex.Lock()
+	subscribers[ch] = struct{}{}
+	mutex.Unlock()
+
+	once := sync.Once{}
+	return ch, func() {
+		once.Do(func() {
+			mutex.Lock()
+			delete(subscribers, ch)
+			mutex.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func Publish(event Event) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	for ch := range subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
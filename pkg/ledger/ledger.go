@@ -0,0 +1,134 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/market"
+
+	"github.com/linxGnu/grocksdb"
+)
+
+var ErrUnbalanced = errors.New("unbalanced ledger transaction")
+
+// Transaction is a set of entries whose debits & credits balance in each asset
+type Transaction struct {
+	Entries []models.LedgerEntry
+}
+
+func (t *Transaction) add(entry models.LedgerEntry, account models.LedgerAccount, asset string, amount models.Decimal, credit bool) {
+	if amount.Sign() == 0 {
+		return
+	}
+	entry.Account = account
+	entry.Asset = asset
+	if credit {
+		entry.Credit = amount
+	} else {
+		entry.Debit = amount
+	}
+	t.Entries = append(t.Entries, entry)
+}
+
+func (t *Transaction) debit(entry models.LedgerEntry, account models.LedgerAccount, asset string, amount models.Decimal) {
+	t.add(entry, account, asset, amount, false)
+}
+
+func (t *Transaction) credit(entry models.LedgerEntry, account models.LedgerAccount, asset string, amount models.Decimal) {
+	t.add(entry, account, asset, amount, true)
+}
+
+// Balanced reports whether the debits & credits of each asset are equal
+func (t *Transaction) Balanced() bool {
+	sums := map[string]models.Decimal{}
+	for i := range t.Entries {
+		sums[t.Entries[i].Asset] = sums[t.Entries[i].Asset].Add(t.Entries[i].Amount())
+	}
+	for _, sum := range sums {
+		if sum.Sign() != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Fill returns the transaction of a trade: the seller's base goes to the buyer & the buyer's quote to the seller.
+// Each side pays the fee of its market role, maker or taker, in the asset it receives, to the fee account.
+func Fill(trade *models.Trade) *Transaction {
+	m := market.Get(trade.Symbol)
+	value := trade.Price.Mul(trade.Quantity)
+	buyer, seller := models.UserAccount(trade.TakerUserId), models.UserAccount(trade.MakerUserId)
+	buyerFee, sellerFee := m.Fees.Taker, m.Fees.Maker
+	if trade.Side == models.SELL {
+		buyer, seller = seller, buyer
+		buyerFee, sellerFee = sellerFee, buyerFee
+	}
+
+	tx := &Transaction{}
+	entry := models.LedgerEntry{Type: models.FILL, Symbol: trade.Symbol, TradeSequence: trade.Sequence, Timestamp: trade.Timestamp}
+	// Debits come first, so that funds are taken before being received
+	tx.debit(entry, buyer, m.Quote, value)
+	tx.debit(entry, seller, m.Base, trade.Quantity)
+	tx.credit(entry, buyer, m.Base, trade.Quantity)
+	tx.credit(entry, seller, m.Quote, value)
+
+	entry.Type = models.FEE
+	baseFee, quoteFee := trade.Quantity.Mul(buyerFee), value.Mul(sellerFee)
+	tx.debit(entry, buyer, m.Base, baseFee)
+	tx.credit(entry, models.FEES_ACCOUNT, m.Base, baseFee)
+	tx.debit(entry, seller, m.Quote, quoteFee)
+	tx.credit(entry, models.FEES_ACCOUNT, m.Quote, quoteFee)
+	return tx
+}
+
+// Transfer returns the transaction of a deposit or a withdrawal of a user, from or to the external account
+func Transfer(userId uint64, asset string, amount models.Decimal, withdrawal bool, timestamp uint64) *Transaction {
+	tx := &Transaction{}
+	entry := models.LedgerEntry{Type: models.DEPOSIT, Timestamp: timestamp}
+	from, to := models.EXTERNAL_ACCOUNT, models.UserAccount(userId)
+	if withdrawal {
+		entry.Type = models.WITHDRAWAL
+		from, to = to, from
+	}
+	tx.debit(entry, from, asset, amount)
+	tx.credit(entry, to, asset, amount)
+	return tx
+}
+
+// Journal collects the transactions written with a batch.
+// The matching mutex must be locked until the batch is written, so that sequences are not reused.
+type Journal struct {
+	transactions []*Transaction
+}
+
+// Record adds a balanced transaction to the journal
+func (j *Journal) Record(tx *Transaction) error {
+	if !tx.Balanced() {
+		return fmt.Errorf("%w: %v", ErrUnbalanced, tx.Entries)
+	}
+	j.transactions = append(j.transactions, tx)
+	return nil
+}
+
+// Write numbers the entries after the last one of the journal, and appends them with the batch
+func (j *Journal) Write(batch *grocksdb.WriteBatch) error {
+	if len(j.transactions) == 0 {
+		return nil
+	}
+	seq, err := rocksdb.LastLedgerSequence()
+	if err != nil {
+		return err
+	}
+	entries := make([]models.LedgerEntry, 0)
+	for _, tx := range j.transactions {
+		transactionId := seq + 1
+		for _, entry := range tx.Entries {
+			seq++
+			entry.Sequence = seq
+			entry.TransactionId = transactionId
+			entries = append(entries, entry)
+		}
+	}
+	return rocksdb.PutLedgerEntries(batch, entries)
+}
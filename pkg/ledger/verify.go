@@ -0,0 +1,102 @@
+package ledger
+
+import (
+	"fmt"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/rocksdb"
+)
+
+// Report is the result of a verification of the ledger
+type Report struct {
+	Entries      uint64 `json:"entries"`
+	Transactions uint64 `json:"transactions"`
+	Balances     uint64 `json:"balances"`
+	// Funds of the system accounts by asset, e.g. the collected fees
+	SystemAccounts map[models.LedgerAccount]map[string]models.Decimal `json:"systemAccounts"`
+	// Every discrepancy found, none if the ledger is consistent
+	Errors []string `json:"errors"`
+}
+
+func (r *Report) errorf(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+// Verify replays the journal and checks that it is consistent with the balances:
+// entries are numbered without gaps, every transaction balances in each asset,
+// and the funds of each user in each asset equal the sum of its entries.
+// It reads the whole database, and is meant to run offline on a stopped server.
+func Verify() (*Report, error) {
+	report := &Report{
+		SystemAccounts: map[models.LedgerAccount]map[string]models.Decimal{},
+		Errors:         make([]string, 0),
+	}
+	userSums := map[string]models.Decimal{}
+	lastSequence := uint64(0)
+
+	tx := &Transaction{}
+	checkTransaction := func() {
+		if len(tx.Entries) > 0 && !tx.Balanced() {
+			report.errorf("transaction %d is unbalanced", tx.Entries[0].TransactionId)
+		}
+	}
+	err := rocksdb.IterateLedger(func(entry *models.LedgerEntry) error {
+		report.Entries++
+		if entry.Sequence != lastSequence+1 {
+			report.errorf("entry %d follows entry %d", entry.Sequence, lastSequence)
+		}
+		lastSequence = entry.Sequence
+		if len(tx.Entries) == 0 || entry.TransactionId != tx.Entries[0].TransactionId {
+			checkTransaction()
+			tx = &Transaction{}
+			report.Transactions++
+		}
+		tx.Entries = append(tx.Entries, *entry)
+
+		if userId, ok := entry.Account.UserId(); ok {
+			key := balanceKey(userId, entry.Asset)
+			userSums[key] = userSums[key].Add(entry.Amount())
+			return nil
+		}
+		sums, ok := report.SystemAccounts[entry.Account]
+		if !ok {
+			sums = map[string]models.Decimal{}
+			report.SystemAccounts[entry.Account] = sums
+		}
+		sums[entry.Asset] = sums[entry.Asset].Add(entry.Amount())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	checkTransaction()
+	last, err := rocksdb.LastLedgerSequence()
+	if err != nil {
+		return nil, err
+	}
+	if last != lastSequence {
+		report.errorf("last entry is %d, the sequence is %d", lastSequence, last)
+	}
+
+	err = rocksdb.IterateBalances(func(balance *models.Balance) error {
+		report.Balances++
+		key := balanceKey(balance.UserId, balance.Asset)
+		if sum := userSums[key]; !sum.Equal(balance.Total()) {
+			report.errorf("balance of user %d in %s is %s, its entries sum to %s", balance.UserId, balance.Asset, balance.Total(), sum)
+		}
+		delete(userSums, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for key, sum := range userSums {
+		if sum.Sign() != 0 {
+			report.errorf("entries of %s sum to %s without a balance", key, sum)
+		}
+	}
+	return report, nil
+}
+
+func balanceKey(userId uint64, asset string) string {
+	return fmt.Sprintf("user %d in %s", userId, asset)
+}
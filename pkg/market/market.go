@@ -0,0 +1,123 @@
+package market
+
+import (
+	"encoding/json"
+	"os"
+	"trading-bsx/pkg/db/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+type Market struct {
+	Symbol string `json:"symbol"`
+	Base   string `json:"base"`
+	Quote  string `json:"quote"`
+	Rules  Rules  `json:"rules"`
+	Fees   Fees   `json:"fees"`
+}
+
+// Fees are the rates of the traded value charged to each side of a fill, in the asset it receives
+type Fees struct {
+	Maker models.Decimal `json:"maker"`
+	Taker models.Decimal `json:"taker"`
+}
+
+// validFeeRate reports whether a fee rate leaves a part of the received funds, a rate of 1 takes all of them
+func validFeeRate(rate models.Decimal) bool {
+	return rate.Sign() >= 0 && rate.Cmp(models.NewDecimalFromInt(1)) < 0
+}
+
+// Rules are the trading rules of a market. A zero value disables the rule.
+type Rules struct {
+	// Price must be a multiple of the tick size
+	TickSize models.Decimal `json:"tickSize"`
+	MinPrice models.Decimal `json:"minPrice"`
+	MaxPrice models.Decimal `json:"maxPrice"`
+	// Max relative deviation of price from the last trade price, e.g. 0.1 for 10%
+	PriceBand   models.Decimal `json:"priceBand"`
+	MinQuantity models.Decimal `json:"minQuantity"`
+	MaxQuantity models.Decimal `json:"maxQuantity"`
+	// Quantity must be a multiple of the lot size
+	LotSize models.Decimal `json:"lotSize"`
+	// Min value of price * quantity
+	MinNotional models.Decimal `json:"minNotional"`
+}
+
+// Markets listed when MARKETS_CONFIG is not set
+var DefaultMarkets = []Market{
+	{
+		Symbol: "BTC-USDT",
+		Base:   "BTC",
+		Quote:  "USDT",
+		Rules: Rules{
+			TickSize:    models.MustParseDecimal("0.01"),
+			MinQuantity: models.MustParseDecimal("0.00001"),
+			MaxQuantity: models.MustParseDecimal("1000"),
+			LotSize:     models.MustParseDecimal("0.00001"),
+			MinNotional: models.MustParseDecimal("5"),
+		},
+	},
+	{
+		Symbol: "ETH-USDT",
+		Base:   "ETH",
+		Quote:  "USDT",
+		Rules: Rules{
+			TickSize:    models.MustParseDecimal("0.01"),
+			MinQuantity: models.MustParseDecimal("0.0001"),
+			MaxQuantity: models.MustParseDecimal("10000"),
+			LotSize:     models.MustParseDecimal("0.0001"),
+			MinNotional: models.MustParseDecimal("5"),
+		},
+	},
+}
+
+var markets = map[string]*Market{}
+var symbols = make([]string, 0)
+
+func Init() {
+	list := append([]Market{}, DefaultMarkets...)
+	if path := os.Getenv("MARKETS_CONFIG"); len(path) > 0 {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+		list = make([]Market, 0)
+		if err := json.Unmarshal(data, &list); err != nil {
+			panic(err)
+		}
+	}
+
+	markets = map[string]*Market{}
+	symbols = make([]string, 0, len(list))
+	for i := range list {
+		if _, ok := markets[list[i].Symbol]; ok {
+			panic("duplicated market " + list[i].Symbol)
+		}
+		if !validFeeRate(list[i].Fees.Maker) || !validFeeRate(list[i].Fees.Taker) {
+			panic("fees of market " + list[i].Symbol + " must be at least 0 and less than 1")
+		}
+		markets[list[i].Symbol] = &list[i]
+		symbols = append(symbols, list[i].Symbol)
+	}
+
+	log.Info().Strs("symbols", symbols).Msg("Markets loaded")
+}
+
+// Get returns the listed market of the symbol, or nil if it is not listed
+func Get(symbol string) *Market {
+	return markets[symbol]
+}
+
+func Symbols() []string {
+	return symbols
+}
+
+// IsAsset reports whether the asset is the base or the quote of a listed market
+func IsAsset(asset string) bool {
+	for _, m := range markets {
+		if m.Base == asset || m.Quote == asset {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Bucket is a token bucket of Capacity tokens, refilled by Rate tokens per second. A zero capacity disables it.
+type Bucket struct {
+	Capacity float64 `json:"capacity"`
+	Rate     float64 `json:"rate"`
+}
+
+// Weights are the tokens taken by each kind of request
+type Weights struct {
+	// Placing & amending orders
+	Place  float64 `json:"place"`
+	Cancel float64 `json:"cancel"`
+	Query  float64 `json:"query"`
+}
+
+type Limits struct {
+	User    Bucket  `json:"user"`
+	ApiKey  Bucket  `json:"apiKey"`
+	IP      Bucket  `json:"ip"`
+	Weights Weights `json:"weights"`
+	// Max open orders of a user, 0 to disable
+	MaxOpenOrders int64 `json:"maxOpenOrders"`
+}
+
+// Limits applied when RATE_LIMITS_CONFIG is not set, or to the fields it omits
+var DefaultLimits = Limits{
+	User:   Bucket{Capacity: 200, Rate: 50},
+	ApiKey: Bucket{Capacity: 100, Rate: 20},
+	IP:     Bucket{Capacity: 600, Rate: 100},
+	Weights: Weights{
+		Place:  2,
+		Cancel: 1,
+		Query:  1,
+	},
+	MaxOpenOrders: 1000,
+}
+
+var limits = DefaultLimits
+
+var (
+	UserLimiter   *Limiter
+	ApiKeyLimiter *Limiter
+	IPLimiter     *Limiter
+)
+
+func Init() {
+	limits = DefaultLimits
+	if path := os.Getenv("RATE_LIMITS_CONFIG"); len(path) > 0 {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+		if err := json.Unmarshal(data, &limits); err != nil {
+			panic(err)
+		}
+	}
+	UserLimiter = NewLimiter(limits.User)
+	ApiKeyLimiter = NewLimiter(limits.ApiKey)
+	IPLimiter = NewLimiter(limits.IP)
+
+	log.Info().Interface("limits", limits).Msg("Rate limits loaded")
+}
+
+func Get() Limits {
+	return limits
+}
+
+type bucketState struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// Limiter holds a token bucket per key, e.g. per user
+type Limiter struct {
+	bucket    Bucket
+	mutex     sync.Mutex
+	states    map[string]*bucketState
+	lastPurge time.Time
+}
+
+func NewLimiter(bucket Bucket) *Limiter {
+	return &Limiter{bucket: bucket, states: map[string]*bucketState{}}
+}
+
+// Result is the state of a bucket after a request
+type Result struct {
+	Allowed   bool
+	Limit     float64
+	Remaining float64
+	// Time until the bucket is full again, or until the request would be allowed if it is not
+	Reset time.Duration
+}
+
+// Enabled reports whether the limiter has a bucket
+func (l *Limiter) Enabled() bool {
+	return l.bucket.Capacity > 0
+}
+
+// Take takes the weight from the bucket of the key if it holds enough tokens
+func (l *Limiter) Take(key string, weight float64, now time.Time) Result {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.purge(now)
+
+	state, ok := l.states[key]
+	if !ok {
+		state = &bucketState{tokens: l.bucket.Capacity, updatedAt: now}
+		l.states[key] = state
+	}
+	l.refill(state, now)
+
+	result := Result{Limit: l.bucket.Capacity}
+	if state.tokens >= weight {
+		state.tokens -= weight
+		result.Allowed = true
+		result.Reset = l.duration(l.bucket.Capacity - state.tokens)
+	} else {
+		result.Reset = l.duration(weight - state.tokens)
+	}
+	result.Remaining = state.tokens
+	return result
+}
+
+// Refund gives back the weight taken by a request which was rejected by another limiter
+func (l *Limiter) Refund(key string, weight float64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if state, ok := l.states[key]; ok {
+		state.tokens = math.Min(state.tokens+weight, l.bucket.Capacity)
+	}
+}
+
+func (l *Limiter) refill(state *bucketState, now time.Time) {
+	if elapsed := now.Sub(state.updatedAt).Seconds(); elapsed > 0 {
+		state.tokens = math.Min(state.tokens+elapsed*l.bucket.Rate, l.bucket.Capacity)
+		state.updatedAt = now
+	}
+}
+
+// duration is the time to refill the tokens
+func (l *Limiter) duration(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	if l.bucket.Rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(math.Ceil(tokens / l.bucket.Rate * float64(time.Second)))
+}
+
+// purge forgets the full buckets, which are the same as new ones. The mutex must be locked.
+func (l *Limiter) purge(now time.Time) {
+	if now.Sub(l.lastPurge) < time.Minute {
+		return
+	}
+	for key, state := range l.states {
+		l.refill(state, now)
+		if state.tokens >= l.bucket.Capacity {
+			delete(l.states, key)
+		}
+	}
+	l.lastPurge = now
+}
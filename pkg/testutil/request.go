@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"net/http/httptest"
 	"strconv"
+	"time"
+	"trading-bsx/pkg/auth"
 
 	"github.com/labstack/echo/v4"
 )
@@ -14,34 +16,84 @@ type RequestOption struct {
 	URL         string
 	Body        interface{}
 	ContentType string
+	Headers     map[string]string
 }
 
 type Client struct {
 	userId uint64
 	server *echo.Echo
+	// Signs the tokens of the client, trusted by the server
+	key SigningKey
+	// When set, requests are signed with the API key instead of a token
+	apiKey    string
+	apiSecret string
 }
 
+// NewClient creates a client of the server, whose signing key is trusted by the server
 func NewClient(e *echo.Echo) *Client {
-	return &Client{server: e}
+	key := NewSigningKey("testutil", auth.HS256)
+	auth.AddKey(key.VerificationKey())
+	return &Client{server: e, key: key}
 }
 
 func (c *Client) SetUser(userId uint64) {
 	c.userId = userId
 }
 
+// SetApiKey signs the next requests with the API key, or with tokens again if the key is empty
+func (c *Client) SetApiKey(key string, secret string) {
+	c.apiKey = key
+	c.apiSecret = secret
+}
+
+// Key is the signing key of the client
+func (c *Client) Key() SigningKey {
+	return c.key
+}
+
+// Claims are the claims of a token of the user, valid for an hour
+func (c *Client) Claims() auth.Claims {
+	now := time.Now()
+	claims := auth.Claims{
+		Subject:   strconv.FormatUint(c.userId, 10),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	}
+	if aud := auth.ExpectedAudience(); len(aud) > 0 {
+		claims.Audience = auth.Audience{aud}
+	}
+	return claims
+}
+
+// Authorization is the value of the Authorization header identifying the user
+func (c *Client) Authorization() string {
+	return "Bearer " + SignToken(c.key, c.Claims())
+}
+
 func (c *Client) Request(opts *RequestOption) *httptest.ResponseRecorder {
 	var reqBody bytes.Buffer
 	err := json.NewEncoder(&reqBody).Encode(opts.Body)
 	if err != nil {
 		panic(err)
 	}
+	body := reqBody.Bytes()
 	req := httptest.NewRequest(opts.Method, opts.URL, &reqBody)
 	if opts.ContentType != "" {
 		req.Header.Set(echo.HeaderContentType, opts.ContentType)
 	} else {
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	}
-	req.Header.Set(echo.HeaderAuthorization, strconv.FormatUint(c.userId, 10))
+	if len(c.apiKey) > 0 {
+		timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		req.Header.Set(auth.HeaderApiKey, c.apiKey)
+		req.Header.Set(auth.HeaderApiTimestamp, timestamp)
+		req.Header.Set(auth.HeaderApiSignature, auth.Sign(c.apiSecret, timestamp, opts.Method, opts.URL, body))
+	} else {
+		req.Header.Set(echo.HeaderAuthorization, c.Authorization())
+	}
+	for key, value := range opts.Headers {
+		req.Header.Set(key, value)
+	}
 	res := httptest.NewRecorder()
 	c.server.ServeHTTP(res, req)
 
@@ -0,0 +1,126 @@
+package testutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"trading-bsx/pkg/auth"
+)
+
+// SigningKey signs tokens. Key is the secret of HS256, a *rsa.PrivateKey for RS256 or an *ecdsa.PrivateKey for ES256.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Key       interface{}
+}
+
+// NewSigningKey generates a key of the algorithm
+func NewSigningKey(id string, algorithm string) SigningKey {
+	key := SigningKey{ID: id, Algorithm: algorithm}
+	var err error
+	switch algorithm {
+	case auth.HS256:
+		secret := make([]byte, 32)
+		_, err = rand.Read(secret)
+		key.Key = secret
+	case auth.RS256:
+		key.Key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case auth.ES256:
+		key.Key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		panic("unsupported algorithm " + algorithm)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// VerificationKey is the key verifying the tokens signed by the key
+func (k SigningKey) VerificationKey() auth.Key {
+	key := auth.Key{ID: k.ID, Algorithm: k.Algorithm}
+	switch signer := k.Key.(type) {
+	case []byte:
+		key.Secret = signer
+	case *rsa.PrivateKey:
+		key.PublicKey = &signer.PublicKey
+	case *ecdsa.PrivateKey:
+		key.PublicKey = &signer.PublicKey
+	}
+	return key
+}
+
+// JWK is the JSON Web Key verifying the tokens signed by the key
+func (k SigningKey) JWK() auth.JWK {
+	jwk := auth.JWK{Kid: k.ID, Alg: k.Algorithm, Use: "sig"}
+	switch signer := k.Key.(type) {
+	case []byte:
+		jwk.Kty = "oct"
+		jwk.K = base64.RawURLEncoding.EncodeToString(signer)
+	case *rsa.PrivateKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(signer.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(signer.E)).Bytes())
+	case *ecdsa.PrivateKey:
+		jwk.Kty = "EC"
+		jwk.Crv = "P-256"
+		jwk.X = base64.RawURLEncoding.EncodeToString(signer.X.FillBytes(make([]byte, 32)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(signer.Y.FillBytes(make([]byte, 32)))
+	}
+	return jwk
+}
+
+// WriteJWKS writes the JWKS file of the keys
+func WriteJWKS(path string, keys ...SigningKey) error {
+	jwks := auth.JWKS{Keys: make([]auth.JWK, len(keys))}
+	for i := range keys {
+		jwks.Keys[i] = keys[i].JWK()
+	}
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// SignToken mints a compact JWS token of the claims, which may be any JSON value
+func SignToken(key SigningKey, claims interface{}) string {
+	header, err := json.Marshal(auth.Header{Alg: key.Algorithm, Kid: key.ID, Typ: "JWT"})
+	if err != nil {
+		panic(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		panic(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch signer := key.Key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, signer)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, signer, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, signer, digest[:])
+		if err == nil {
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	}
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
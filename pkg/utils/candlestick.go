@@ -0,0 +1,28 @@
+package utils
+
+import "time"
+
+var CandlestickIntervals = []Duration{FiveMinutes, ThirtyMinutes, OneHour, FourHours, OneDay, OneWeek, OneMonth}
+
+// Start returns the start of the candlestick interval containing t, in UTC. Weeks start on Monday.
+func (d Duration) Start(t time.Time) time.Time {
+	t = t.UTC()
+	switch d {
+	case FiveMinutes:
+		return t.Truncate(5 * time.Minute)
+	case ThirtyMinutes:
+		return t.Truncate(30 * time.Minute)
+	case OneHour:
+		return t.Truncate(time.Hour)
+	case FourHours:
+		return t.Truncate(4 * time.Hour)
+	case OneDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case OneWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case OneMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return t
+}
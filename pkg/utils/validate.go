@@ -3,8 +3,12 @@ package utils
 import (
 	"fmt"
 	"net/http"
+	"reflect"
+	"regexp"
 	"strings"
 	"time"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/market"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
@@ -26,6 +30,8 @@ const (
 
 var (
 	_2021_TIMESTAMP = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC).UnixMicro()
+	// Client order IDs are used in URL paths
+	clientOrderIdRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
 )
 
 // CustomValidator is type setting of third party validator
@@ -39,12 +45,16 @@ func NewValidator() *CustomValidator {
 		validator: validator.New(),
 	}
 
-	cv.validator.RegisterValidation("price", func(fl validator.FieldLevel) bool {
-		if price, ok := fl.Field().Interface().(float64); ok {
-			return price > 0
+	// Decimals are validated as strings. Zero decimals are empty strings, so that required & omitempty work with them
+	cv.validator.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		if amount, ok := field.Interface().(models.Decimal); ok && !amount.IsZero() {
+			return amount.String()
 		}
-		return false
-	})
+		return ""
+	}, models.Decimal{})
+
+	cv.validator.RegisterValidation("price", validatePositiveDecimal)
+	cv.validator.RegisterValidation("quantity", validatePositiveDecimal)
 
 	cv.validator.RegisterValidation("candlestick_interval", func(fl validator.FieldLevel) bool {
 		if interval, ok := fl.Field().Interface().(Duration); ok {
@@ -73,16 +83,44 @@ func NewValidator() *CustomValidator {
 	})
 
 	cv.validator.RegisterValidation("valid_symbol", func(fl validator.FieldLevel) bool {
-		return true
+		if symbol, ok := fl.Field().Interface().(string); ok {
+			return market.Get(symbol) != nil
+		}
+		return false
+	})
+
+	cv.validator.RegisterValidation("client_order_id", func(fl validator.FieldLevel) bool {
+		if id, ok := fl.Field().Interface().(string); ok {
+			return clientOrderIdRegex.MatchString(id)
+		}
+		return false
 	})
 
+	// Users have a wallet in each asset of the listed markets
 	cv.validator.RegisterValidation("valid_wallet_type", func(fl validator.FieldLevel) bool {
-		return true
+		if asset, ok := fl.Field().Interface().(string); ok {
+			return market.IsAsset(asset)
+		}
+		return false
 	})
 
 	return cv
 }
 
+// Amounts must be positive, fit in the 16 bytes of the order book and in the Decimal128 of MongoDB
+func validatePositiveDecimal(fl validator.FieldLevel) bool {
+	if str, ok := fl.Field().Interface().(string); ok {
+		amount, err := models.ParseDecimal(str)
+		if err != nil {
+			return false
+		}
+		_, err = amount.Bytes16()
+		_, fits := amount.Decimal128()
+		return amount.Sign() > 0 && err == nil && fits
+	}
+	return false
+}
+
 type ValidationError struct {
 	Message  string                 `json:"message"`
 	Metadata map[string]interface{} `json:"metadata"`
@@ -196,10 +234,19 @@ func translateError(validateErr validator.FieldError) string {
 		msg = fmt.Sprintf("%s must be equal to %s", field, strings.ToLower(validateErr.Param()))
 	case "nefield":
 		msg = fmt.Sprintf("%s must not be equal to %s", field, strings.ToLower(validateErr.Param()))
+	case "price", "quantity":
+		msg = fmt.Sprintf("%s must be a positive decimal", field)
+	case "valid_symbol":
+		msg = fmt.Sprintf("%s is not a listed market", field)
+	case "client_order_id":
+		msg = fmt.Sprintf("%s must have 1 to 64 letters, numbers, - or _", field)
 	case "required_with":
 		msg = fmt.Sprintf("%s is required when %s is present", field, validateErr.Param())
 	case "required_without":
 		msg = fmt.Sprintf("%s is required when %s is not present", field, validateErr.Param())
+	case "required_unless":
+		params := strings.Split(validateErr.Param(), " ")
+		msg = fmt.Sprintf("%s is required unless %s is %s", field, pascalCaseToWords(params[0]), strings.Join(params[1:], " or "))
 	case "required_if":
 		params := strings.Split(validateErr.Param(), " ")
 		comparedField := strings.ToLower(params[0])
@@ -3,6 +3,7 @@ package engine_bench_test
 import (
 	"math/rand/v2"
 	"net/http"
+	"strconv"
 	"testing"
 	"trading-bsx/cmd/api/server"
 	"trading-bsx/internal/trade"
@@ -10,8 +11,22 @@ import (
 	"trading-bsx/pkg/testutil"
 )
 
+const testSymbol = "BTC-USDT"
+
+// fundUsers credits the users placing the orders, 0 to n-1, with enough funds for any benchmark
+func fundUsers(b *testing.B, n uint64) {
+	for userId := uint64(0); userId < n; userId++ {
+		for _, asset := range []string{"BTC", "USDT"} {
+			if _, err := trade.TransferFunds(userId, asset, models.MustParseDecimal("1000000000"), false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
 func Benchmark_PlaceOnlyOneOrderType(b *testing.B) {
 	b.Setenv("ENV", "test")
+	b.Setenv("RATE_LIMITS_CONFIG", "testdata/no_rate_limits.json")
 	s := server.New()
 	defer s.Close()
 
@@ -19,6 +34,8 @@ func Benchmark_PlaceOnlyOneOrderType(b *testing.B) {
 	const maxPrice = 200.0
 
 	client := testutil.NewClient(s)
+	fundUsers(b, 200)
+	b.ResetTimer()
 
 	for j := 0; j < b.N; j++ {
 		client.SetUser(rand.Uint64N(200))
@@ -27,8 +44,10 @@ func Benchmark_PlaceOnlyOneOrderType(b *testing.B) {
 			Method: http.MethodPost,
 			URL:    "/orders",
 			Body: trade.CreateOrder{
-				Type:  models.BUY,
-				Price: price,
+				Symbol:   testSymbol,
+				Type:     models.BUY,
+				Price:    models.MustParseDecimal(strconv.FormatFloat(price, 'f', 2, 64)),
+				Quantity: models.MustParseDecimal("1"),
 			},
 		})
 	}
@@ -36,6 +55,7 @@ func Benchmark_PlaceOnlyOneOrderType(b *testing.B) {
 
 func Benchmark_PlaceRandomBuyNSellOrders(b *testing.B) {
 	b.Setenv("ENV", "test")
+	b.Setenv("RATE_LIMITS_CONFIG", "testdata/no_rate_limits.json")
 	s := server.New()
 	defer s.Close()
 
@@ -43,6 +63,8 @@ func Benchmark_PlaceRandomBuyNSellOrders(b *testing.B) {
 	const maxPrice = 200.0
 
 	client := testutil.NewClient(s)
+	fundUsers(b, 200)
+	b.ResetTimer()
 
 	for j := 0; j < b.N; j++ {
 		var orderType models.OrderType
@@ -58,8 +80,10 @@ func Benchmark_PlaceRandomBuyNSellOrders(b *testing.B) {
 			Method: http.MethodPost,
 			URL:    "/orders",
 			Body: trade.CreateOrder{
-				Type:  orderType,
-				Price: price,
+				Symbol:   testSymbol,
+				Type:     orderType,
+				Price:    models.MustParseDecimal(strconv.FormatFloat(price, 'f', 2, 64)),
+				Quantity: models.MustParseDecimal("1"),
 			},
 		})
 	}
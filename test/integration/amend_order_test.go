@@ -0,0 +1,144 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func placeSell(t *testing.T, client *testutil.Client, price string, quantity string) models.Order {
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal(price),
+			Quantity: models.MustParseDecimal(quantity),
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	return result.Order
+}
+
+func amendOrder(client *testutil.Client, orderId string, body map[string]interface{}) (int, models.Order) {
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPatch,
+		URL:    "/orders/" + orderId,
+		Body:   body,
+	})
+	order := models.Order{}
+	json.NewDecoder(res.Body).Decode(&order)
+	return res.Code, order
+}
+
+func Test_AmendOrder_QuantityDecreaseKeepsPriority(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	first := placeSell(t, client, "100", "2")
+	client.SetUser(3)
+	placeSell(t, client, "100", "1")
+
+	client.SetUser(1)
+	code, amended := amendOrder(client, first.ID.Hex(), map[string]interface{}{"quantity": "1"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, uint64(2), amended.Version)
+	assert.Equal(t, "1", amended.Quantity.String())
+	assert.Equal(t, "1", amended.Remaining.String())
+	assert.Equal(t, first.Key, amended.Key)
+	assert.Equal(t, first.Timestamp, amended.Timestamp)
+
+	client.SetUser(2)
+	code, result := placeBuy(client, "100", "1", "")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, result.Fills, 1) {
+		assert.Equal(t, first.ID.Hex(), result.Fills[0].MakerOrderId.Hex())
+		assert.Equal(t, "1", result.Fills[0].Quantity.String())
+	}
+}
+
+func Test_AmendOrder_PriceChangeLosesPriority(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	first := placeSell(t, client, "101", "1")
+	client.SetUser(3)
+	second := placeSell(t, client, "100", "1")
+
+	client.SetUser(1)
+	code, amended := amendOrder(client, first.ID.Hex(), map[string]interface{}{"price": "100"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "100", amended.Price.String())
+	assert.NotEqual(t, first.Key, amended.Key)
+	assert.Greater(t, amended.Timestamp, second.Timestamp)
+
+	client.SetUser(2)
+	code, result := placeBuy(client, "100", "1", "")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, result.Fills, 1) {
+		assert.Equal(t, second.ID.Hex(), result.Fills[0].MakerOrderId.Hex())
+	}
+	code, result = placeBuy(client, "100", "1", "")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, result.Fills, 1) {
+		assert.Equal(t, first.ID.Hex(), result.Fills[0].MakerOrderId.Hex())
+	}
+}
+
+func Test_AmendOrder_Reject(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	placeSell(t, client, "100", "1")
+	order := placeSell(t, client, "102", "2")
+
+	client.SetUser(2)
+	code, _ := placeBuy(client, "102", "2", "")
+	assert.Equal(t, http.StatusOK, code)
+	code, buyOrder := placeBuy(client, "99", "1", "")
+	assert.Equal(t, http.StatusOK, code)
+
+	client.SetUser(1)
+	// Nothing to amend
+	code, _ = amendOrder(client, order.ID.Hex(), map[string]interface{}{})
+	assert.Equal(t, http.StatusBadRequest, code)
+	// 1 is already filled
+	code, _ = amendOrder(client, order.ID.Hex(), map[string]interface{}{"quantity": "1"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, amended := amendOrder(client, order.ID.Hex(), map[string]interface{}{"quantity": "3"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "2", amended.Remaining.String())
+	assert.Equal(t, models.PARTIALLY_FILLED, amended.Status)
+	// Not an order of the user
+	code, _ = amendOrder(client, buyOrder.Order.ID.Hex(), map[string]interface{}{"price": "98"})
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = amendOrder(client, primitive.NewObjectID().Hex(), map[string]interface{}{"price": "98"})
+	assert.Equal(t, http.StatusNotFound, code)
+
+	// A new price crossing the book is rejected
+	client.SetUser(2)
+	code, _ = amendOrder(client, buyOrder.Order.ID.Hex(), map[string]interface{}{"price": "102"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, amended = amendOrder(client, buyOrder.Order.ID.Hex(), map[string]interface{}{"price": "99.5"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "99.5", amended.Price.String())
+}
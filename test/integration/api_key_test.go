@@ -0,0 +1,109 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/account"
+	"trading-bsx/pkg/auth"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createApiKey(t *testing.T, client *testutil.Client, permissions ...models.ApiKeyPermission) account.CreatedApiKey {
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/api-keys",
+		Body:   account.CreateApiKeyBody{Name: "bot", Permissions: permissions},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	apiKey := account.CreatedApiKey{}
+	json.Unmarshal(res.Body.Bytes(), &apiKey)
+	return apiKey
+}
+
+func Test_ApiKey_SignsRequests(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+
+	tradeKey := createApiKey(t, client, models.TRADE)
+	assert.Len(t, tradeKey.Secret, 64)
+	readKey := createApiKey(t, client, models.READ)
+
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/api-keys",
+		Body:   map[string]interface{}{"permissions": []string{"ADMIN"}},
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	// Secrets are only returned on creation
+	res = client.Request(&testutil.RequestOption{Method: http.MethodGet, URL: "/api-keys"})
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotContains(t, res.Body.String(), tradeKey.Secret)
+	apiKeys := []models.ApiKey{}
+	json.Unmarshal(res.Body.Bytes(), &apiKeys)
+	assert.Len(t, apiKeys, 2)
+	assert.Equal(t, readKey.Key, apiKeys[0].Key)
+
+	client.SetApiKey(tradeKey.Key, tradeKey.Secret)
+	placeSell(t, client, "100", "1")
+	assert.Len(t, listOrders(t, client, "/orders"), 1)
+	// API keys can't manage API keys
+	res = client.Request(&testutil.RequestOption{Method: http.MethodGet, URL: "/api-keys"})
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+	client.SetApiKey(readKey.Key, readKey.Secret)
+	assert.Len(t, listOrders(t, client, "/orders"), 1)
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodDelete,
+		URL:    "/orders",
+	})
+	assert.Equal(t, http.StatusForbidden, res.Code)
+
+	// Signed headers: a stale timestamp, a wrong signature & a replayed request are rejected
+	signedHeaders := func(timestamp time.Time, secret string) map[string]string {
+		ts := strconv.FormatInt(timestamp.UnixMilli(), 10)
+		return map[string]string{
+			auth.HeaderApiKey:       readKey.Key,
+			auth.HeaderApiTimestamp: ts,
+			auth.HeaderApiSignature: auth.Sign(secret, ts, http.MethodGet, "/orders", []byte("null\n")),
+		}
+	}
+	client.SetApiKey("", "")
+	headers := signedHeaders(time.Now().Add(-time.Minute), readKey.Secret)
+	res = client.Request(&testutil.RequestOption{Method: http.MethodGet, URL: "/orders", Headers: headers})
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	headers = signedHeaders(time.Now(), tradeKey.Secret)
+	res = client.Request(&testutil.RequestOption{Method: http.MethodGet, URL: "/orders", Headers: headers})
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	headers = signedHeaders(time.Now(), readKey.Secret)
+	res = client.Request(&testutil.RequestOption{Method: http.MethodGet, URL: "/orders", Headers: headers})
+	assert.Equal(t, http.StatusOK, res.Code)
+	res = client.Request(&testutil.RequestOption{Method: http.MethodGet, URL: "/orders", Headers: headers})
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+	// Only the owner can revoke a key
+	client.SetUser(2)
+	res = client.Request(&testutil.RequestOption{Method: http.MethodDelete, URL: "/api-keys/" + tradeKey.ID.Hex()})
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	client.SetUser(1)
+	res = client.Request(&testutil.RequestOption{Method: http.MethodDelete, URL: "/api-keys/" + tradeKey.ID.Hex()})
+	assert.Equal(t, http.StatusOK, res.Code)
+	revoked := models.ApiKey{}
+	json.Unmarshal(res.Body.Bytes(), &revoked)
+	assert.NotZero(t, revoked.RevokedAt)
+
+	client.SetApiKey(tradeKey.Key, tradeKey.Secret)
+	res = client.Request(&testutil.RequestOption{Method: http.MethodGet, URL: "/orders"})
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+}
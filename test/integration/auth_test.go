@@ -0,0 +1,96 @@
+package engine_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/pkg/auth"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func authorizedCode(client *testutil.Client, authorization string) int {
+	res := client.Request(&testutil.RequestOption{
+		Method:  http.MethodGet,
+		URL:     "/account",
+		Headers: map[string]string{"Authorization": authorization},
+	})
+	return res.Code
+}
+
+func Test_Auth_VerifiesTokens(t *testing.T) {
+	t.Setenv("ENV", "test")
+	t.Setenv("JWT_AUDIENCE", "trading")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	client.SetUser(1)
+
+	assert.Equal(t, http.StatusOK, authorizedCode(client, client.Authorization()))
+	// The raw user ID is no longer accepted
+	assert.Equal(t, http.StatusUnauthorized, authorizedCode(client, "1"))
+	assert.Equal(t, http.StatusUnauthorized, authorizedCode(client, "Bearer "))
+	assert.Equal(t, http.StatusUnauthorized, authorizedCode(client, "Bearer not.a.token"))
+
+	expired := client.Claims()
+	expired.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	assert.Equal(t, http.StatusUnauthorized, authorizedCode(client, "Bearer "+testutil.SignToken(client.Key(), expired)))
+
+	noExpiry := client.Claims()
+	noExpiry.ExpiresAt = 0
+	assert.Equal(t, http.StatusUnauthorized, authorizedCode(client, "Bearer "+testutil.SignToken(client.Key(), noExpiry)))
+
+	otherAudience := client.Claims()
+	otherAudience.Audience = auth.Audience{"other"}
+	assert.Equal(t, http.StatusUnauthorized, authorizedCode(client, "Bearer "+testutil.SignToken(client.Key(), otherAudience)))
+
+	invalidSubject := client.Claims()
+	invalidSubject.Subject = "alice"
+	assert.Equal(t, http.StatusUnauthorized, authorizedCode(client, "Bearer "+testutil.SignToken(client.Key(), invalidSubject)))
+
+	// Signed by an untrusted key
+	untrusted := testutil.NewSigningKey("testutil", auth.HS256)
+	assert.Equal(t, http.StatusUnauthorized, authorizedCode(client, "Bearer "+testutil.SignToken(untrusted, client.Claims())))
+
+	// Unsigned
+	payload, _ := json.Marshal(client.Claims())
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+	assert.Equal(t, http.StatusUnauthorized, authorizedCode(client, "Bearer "+unsigned))
+}
+
+func Test_Auth_RotatesJWKSKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	rsaKey := testutil.NewSigningKey("rsa-1", auth.RS256)
+	ecKey := testutil.NewSigningKey("ec-1", auth.ES256)
+	if err := testutil.WriteJWKS(path, rsaKey, ecKey); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("ENV", "test")
+	t.Setenv("JWT_JWKS", path)
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	client.SetUser(1)
+
+	assert.Equal(t, http.StatusOK, authorizedCode(client, "Bearer "+testutil.SignToken(rsaKey, client.Claims())))
+	assert.Equal(t, http.StatusOK, authorizedCode(client, "Bearer "+testutil.SignToken(ecKey, client.Claims())))
+
+	// A key ID must match a key of the token algorithm
+	mismatched := testutil.SigningKey{ID: "rsa-1", Algorithm: auth.ES256, Key: ecKey.Key}
+	assert.Equal(t, http.StatusUnauthorized, authorizedCode(client, "Bearer "+testutil.SignToken(mismatched, client.Claims())))
+
+	// The new key is picked up by the first token it signs, the retired one is no longer trusted
+	rotated := testutil.NewSigningKey("rsa-2", auth.RS256)
+	assert.Equal(t, http.StatusUnauthorized, authorizedCode(client, "Bearer "+testutil.SignToken(rotated, client.Claims())))
+	if err := testutil.WriteJWKS(path, rotated, ecKey); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, authorizedCode(client, "Bearer "+testutil.SignToken(rotated, client.Claims())))
+	assert.Equal(t, http.StatusUnauthorized, authorizedCode(client, "Bearer "+testutil.SignToken(rsaKey, client.Claims())))
+}
@@ -0,0 +1,238 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/ledger"
+	"trading-bsx/pkg/market"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fundUsers credits the users with enough funds in every asset to place the orders of a test
+func fundUsers(t *testing.T, userIds ...uint64) {
+	for _, userId := range userIds {
+		for _, symbol := range market.Symbols() {
+			m := market.Get(symbol)
+			for _, asset := range []string{m.Base, m.Quote} {
+				if _, err := trade.TransferFunds(userId, asset, models.MustParseDecimal("1000000000"), false); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func deposit(t *testing.T, userId uint64, asset string, amount string) {
+	if _, err := trade.TransferFunds(userId, asset, models.MustParseDecimal(amount), false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getBalance(t *testing.T, client *testutil.Client, asset string) (string, string) {
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/account/balances?asset=" + asset,
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	balances := []models.Balance{}
+	json.Unmarshal(res.Body.Bytes(), &balances)
+	if len(balances) != 1 {
+		t.Fatalf("expected 1 balance, got %d", len(balances))
+	}
+	return balances[0].Available.String(), balances[0].Locked.String()
+}
+
+func Test_Balances_LockAndSettle(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	deposit(t, 1, "USDT", "1000")
+	deposit(t, 2, "BTC", "10")
+
+	client.SetUser(1)
+	code, _ := placeBuy(client, "100", "2", "")
+	assert.Equal(t, http.StatusOK, code)
+	available, locked := getBalance(t, client, "USDT")
+	assert.Equal(t, "800", available)
+	assert.Equal(t, "200", locked)
+
+	// The order isn't placed without enough funds
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.BUY,
+			Price:    models.MustParseDecimal("100"),
+			Quantity: models.MustParseDecimal("9"),
+		},
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Contains(t, res.Body.String(), "Insufficient USDT balance")
+	assert.Len(t, listOrders(t, client, "/orders"), 1)
+
+	// The maker pays from its locked funds, the taker from its available funds & locks the rest
+	client.SetUser(2)
+	rest := placeSell(t, client, "90", "3")
+	available, locked = getBalance(t, client, "BTC")
+	assert.Equal(t, "7", available)
+	assert.Equal(t, "1", locked)
+	available, locked = getBalance(t, client, "USDT")
+	assert.Equal(t, "200", available)
+	assert.Equal(t, "0", locked)
+
+	client.SetUser(1)
+	available, locked = getBalance(t, client, "USDT")
+	assert.Equal(t, "800", available)
+	assert.Equal(t, "0", locked)
+	available, _ = getBalance(t, client, "BTC")
+	assert.Equal(t, "2", available)
+
+	// A cancel releases the lock
+	client.SetUser(2)
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodDelete,
+		URL:    "/orders/" + rest.ID.Hex(),
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	available, locked = getBalance(t, client, "BTC")
+	assert.Equal(t, "8", available)
+	assert.Equal(t, "0", locked)
+
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/account/withdrawals",
+		Body:   trade.Transfer{Asset: "BTC", Amount: models.MustParseDecimal("9")},
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/account/withdrawals",
+		Body:   trade.Transfer{Asset: "BTC", Amount: models.MustParseDecimal("8")},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	balance := models.Balance{}
+	json.Unmarshal(res.Body.Bytes(), &balance)
+	assert.Equal(t, "0", balance.Available.String())
+}
+
+func Test_Balances_AmendAndDeposit(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	deposit(t, 1, "USDT", "300")
+
+	client.SetUser(1)
+	code, result := placeBuy(client, "50", "2", "")
+	assert.Equal(t, http.StatusOK, code)
+
+	// The amended order locks funds for its new price & quantity
+	code, _ = amendOrder(client, result.Order.ID.Hex(), map[string]interface{}{"quantity": "4"})
+	assert.Equal(t, http.StatusOK, code)
+	available, locked := getBalance(t, client, "USDT")
+	assert.Equal(t, "100", available)
+	assert.Equal(t, "200", locked)
+	code, _ = amendOrder(client, result.Order.ID.Hex(), map[string]interface{}{"price": "80"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	_, locked = getBalance(t, client, "USDT")
+	assert.Equal(t, "200", locked)
+
+	body := trade.Transfer{Asset: "USDT", Amount: models.MustParseDecimal("100")}
+	res := client.Request(&testutil.RequestOption{Method: http.MethodPost, URL: "/account/deposits", Body: body})
+	assert.Equal(t, http.StatusForbidden, res.Code)
+	t.Setenv("DEPOSITS_ENABLED", "true")
+	res = client.Request(&testutil.RequestOption{Method: http.MethodPost, URL: "/account/deposits", Body: body})
+	assert.Equal(t, http.StatusOK, res.Code)
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/account/deposits",
+		Body:   trade.Transfer{Asset: "DOGE", Amount: models.MustParseDecimal("100")},
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	code, _ = amendOrder(client, result.Order.ID.Hex(), map[string]interface{}{"price": "50.5"})
+	assert.Equal(t, http.StatusOK, code)
+	available, locked = getBalance(t, client, "USDT")
+	assert.Equal(t, "198", available)
+	assert.Equal(t, "202", locked)
+}
+
+func Test_Balances_CancelRacesMatch(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	maker := testutil.NewClient(s)
+	maker.SetUser(1)
+	taker := testutil.NewClient(s)
+	taker.SetUser(2)
+	const rounds = 20
+	deposit(t, 1, "USDT", strconv.Itoa(100*rounds))
+	deposit(t, 2, "BTC", strconv.Itoa(rounds))
+
+	filled := 0
+	for i := 0; i < rounds; i++ {
+		code, result := placeBuy(maker, "100", "1", "")
+		assert.Equal(t, http.StatusOK, code)
+
+		// Either the cancel or the crossing order wins, never both
+		var wg sync.WaitGroup
+		var cancelCode int
+		var fills []models.Trade
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			cancelCode = maker.Request(&testutil.RequestOption{
+				Method: http.MethodDelete,
+				URL:    "/orders/" + result.Order.ID.Hex(),
+			}).Code
+		}()
+		go func() {
+			defer wg.Done()
+			res := taker.Request(&testutil.RequestOption{
+				Method: http.MethodPost,
+				URL:    "/orders",
+				Body: trade.CreateOrder{
+					Symbol:      testSymbol,
+					Type:        models.SELL,
+					Price:       models.MustParseDecimal("100"),
+					Quantity:    models.MustParseDecimal("1"),
+					TimeInForce: models.IOC,
+				},
+			})
+			sell := trade.PlaceOrderResult{}
+			json.NewDecoder(res.Body).Decode(&sell)
+			fills = sell.Fills
+		}()
+		wg.Wait()
+
+		_, detail := getOrder(maker, "/orders/"+result.Order.ID.Hex())
+		if len(fills) > 0 {
+			filled++
+			assert.NotEqual(t, http.StatusOK, cancelCode)
+			assert.Equal(t, models.FILLED, detail.Status)
+		} else {
+			assert.Equal(t, http.StatusOK, cancelCode)
+			assert.Equal(t, models.CANCELLED, detail.Status)
+		}
+		assert.Empty(t, getOrderBook(t, maker, "/orderbook?symbol="+testSymbol).Bids)
+	}
+
+	available, locked := getBalance(t, maker, "USDT")
+	assert.Equal(t, strconv.Itoa(100*(rounds-filled)), available)
+	assert.Equal(t, "0", locked)
+	available, _ = getBalance(t, taker, "BTC")
+	assert.Equal(t, strconv.Itoa(rounds-filled), available)
+	report, err := ledger.Verify()
+	assert.Nil(t, err)
+	assert.Empty(t, report.Errors)
+}
@@ -1,6 +1,7 @@
 package engine_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -18,18 +19,23 @@ func Test_CancelOrder(t *testing.T) {
 	s := server.New()
 	defer s.Close()
 	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
 	client.SetUser(1)
 
 	res := client.Request(&testutil.RequestOption{
 		Method: http.MethodPost,
 		URL:    "/orders",
 		Body: trade.CreateOrder{
-			Type:  models.SELL,
-			Price: 100.0,
+			Symbol:   testSymbol,
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal("100"),
+			Quantity: models.MustParseDecimal("1"),
 		},
 	})
 	assert.Equal(t, http.StatusOK, res.Code)
-	orderId := res.Body.String()
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	orderId := result.Order.ID.Hex()
 
 	res = client.Request(&testutil.RequestOption{
 		Method: http.MethodDelete,
@@ -53,18 +59,23 @@ func Test_CancelOrder_MustNotMatch_NewOrder(t *testing.T) {
 	s := server.New()
 	defer s.Close()
 	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
 	client.SetUser(1)
 
 	res := client.Request(&testutil.RequestOption{
 		Method: http.MethodPost,
 		URL:    "/orders",
 		Body: trade.CreateOrder{
-			Type:  models.SELL,
-			Price: 100.0,
+			Symbol:   testSymbol,
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal("100"),
+			Quantity: models.MustParseDecimal("1"),
 		},
 	})
 	assert.Equal(t, http.StatusOK, res.Code)
-	orderId := res.Body.String()
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	orderId := result.Order.ID.Hex()
 
 	res = client.Request(&testutil.RequestOption{
 		Method: http.MethodDelete,
@@ -77,11 +88,15 @@ func Test_CancelOrder_MustNotMatch_NewOrder(t *testing.T) {
 		Method: http.MethodPost,
 		URL:    "/orders",
 		Body: trade.CreateOrder{
-			Type:  models.BUY,
-			Price: 101.0,
+			Symbol:   testSymbol,
+			Type:     models.BUY,
+			Price:    models.MustParseDecimal("101"),
+			Quantity: models.MustParseDecimal("1"),
 		},
 	})
 	assert.Equal(t, http.StatusOK, res.Code)
-	orderId = res.Body.String()
-	assert.Len(t, orderId, 24) // New order's id returned, not match result
-}
\ No newline at end of file
+	result = trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Empty(t, result.Fills) // New order rests on the book, no match result
+	assert.NotNil(t, result.Order.ID)
+}
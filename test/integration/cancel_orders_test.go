@@ -0,0 +1,79 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cancelOrders(t *testing.T, client *testutil.Client, url string) []string {
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodDelete,
+		URL:    url,
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	orderIds := make([]string, 0)
+	json.NewDecoder(res.Body).Decode(&orderIds)
+	return orderIds
+}
+
+func Test_CancelOrders_Filters(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+
+	client.SetUser(2)
+	placeSellLevels(t, client, []string{"150"})
+
+	client.SetUser(1)
+	sellOrders := make([]models.Order, 0)
+	for _, price := range []string{"100", "101", "102"} {
+		sellOrders = append(sellOrders, placeSell(t, client, price, "1"))
+	}
+	code, _ := placeBuy(client, "90", "1", "")
+	assert.Equal(t, http.StatusOK, code)
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/markets/" + otherSymbol + "/orders",
+		Body: trade.CreateOrder{
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal("200"),
+			Quantity: models.MustParseDecimal("1"),
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	orderIds := cancelOrders(t, client, "/orders?type=SELL&minPrice=101&maxPrice=102")
+	assert.ElementsMatch(t, []string{sellOrders[1].ID.Hex(), sellOrders[2].ID.Hex()}, orderIds)
+	assert.Equal(t, 3, countOpenOrders(t, client))
+
+	orderIds = cancelOrders(t, client, "/markets/"+otherSymbol+"/orders")
+	assert.Len(t, orderIds, 1)
+	assert.Equal(t, 0, countBookEntries(rocksdb.Book(otherSymbol).SellOrder))
+
+	orderIds = cancelOrders(t, client, "/orders")
+	assert.Len(t, orderIds, 2)
+	assert.Equal(t, 0, countOpenOrders(t, client))
+	assert.Len(t, getOrdersByStatus(client, models.CANCELLED), 5)
+	assert.Equal(t, 0, countBookEntries(rocksdb.Book(testSymbol).BuyOrder))
+
+	// Orders of other users are untouched
+	assert.Equal(t, 1, countBookEntries(rocksdb.Book(testSymbol).SellOrder))
+	orderIds = cancelOrders(t, client, "/orders")
+	assert.Len(t, orderIds, 0)
+
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodDelete,
+		URL:    "/orders?type=HOLD",
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
@@ -0,0 +1,89 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+	"trading-bsx/pkg/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getCandlesticks(t *testing.T, client *testutil.Client, url string) []models.Candle {
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    url,
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	candles := make([]models.Candle, 0)
+	json.NewDecoder(res.Body).Decode(&candles)
+	return candles
+}
+
+func Test_Candlesticks_Aggregation(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	from := time.Now().Add(-time.Hour).UnixMilli() * 1000
+
+	candles := getCandlesticks(t, client, fmt.Sprintf("/candlesticks?symbol=%s&interval=5m&from=-1", testSymbol))
+	assert.Len(t, candles, 0)
+
+	client.SetUser(1)
+	placeSell(t, client, "100", "1")
+	placeSell(t, client, "102", "1")
+	placeSell(t, client, "104", "1")
+	client.SetUser(2)
+	code, _ := placeBuy(client, "102", "1.5", "")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = placeBuy(client, "104", "1", "")
+	assert.Equal(t, http.StatusOK, code)
+	start := time.Now()
+
+	for _, interval := range utils.CandlestickIntervals {
+		candles = getCandlesticks(t, client, fmt.Sprintf("/candlesticks?symbol=%s&interval=%s&from=%d", testSymbol, interval, from))
+		// The orders may straddle the end of a short interval
+		if !assert.NotEmpty(t, candles, interval) || len(candles) > 1 {
+			continue
+		}
+		candle := candles[0]
+		assert.Equal(t, string(interval), candle.Interval)
+		assert.Equal(t, interval.Start(start).UnixMicro(), candle.OpenTime)
+		assert.Equal(t, "100", candle.Open.String())
+		assert.Equal(t, "104", candle.High.String())
+		assert.Equal(t, "100", candle.Low.String())
+		assert.Equal(t, "104", candle.Close.String())
+		assert.Equal(t, "2.5", candle.Volume.String())
+		assert.Equal(t, "254", candle.QuoteVolume.String())
+		assert.Equal(t, int64(4), candle.Count)
+	}
+
+	candles = getCandlesticks(t, client, fmt.Sprintf("/markets/%s/candlesticks?interval=1d&from=-1", testSymbol))
+	if assert.Len(t, candles, 1) {
+		assert.Equal(t, "104", candles[0].Close.String())
+	}
+	candles = getCandlesticks(t, client, fmt.Sprintf("/candlesticks?symbol=%s&interval=1h&from=%d&to=%d", testSymbol, from, from))
+	assert.Len(t, candles, 0)
+	candles = getCandlesticks(t, client, fmt.Sprintf("/candlesticks?symbol=%s&interval=1h&from=%d", otherSymbol, from))
+	assert.Len(t, candles, 0)
+
+	for _, query := range []string{
+		fmt.Sprintf("symbol=%s&interval=2h&from=%d", testSymbol, from),
+		fmt.Sprintf("symbol=%s&interval=1h", testSymbol),
+		fmt.Sprintf("symbol=%s&interval=1h&from=%d&to=%d", testSymbol, from, from-1000),
+		fmt.Sprintf("interval=1h&from=%d", from),
+	} {
+		res := client.Request(&testutil.RequestOption{
+			Method: http.MethodGet,
+			URL:    "/candlesticks?" + query,
+		})
+		assert.Equal(t, http.StatusBadRequest, res.Code, query)
+	}
+}
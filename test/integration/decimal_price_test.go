@@ -0,0 +1,127 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DecimalPrice_ExactRoundTrip(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: map[string]interface{}{
+			"symbol":   testSymbol,
+			"type":     models.SELL,
+			"price":    "110.2",
+			"quantity": "0.3",
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Equal(t, "110.2", result.Order.Price.String())
+
+	// 0.1 + 0.2 fills 0.3 exactly, no dust is left on the book
+	client.SetUser(2)
+	for _, quantity := range []string{"0.1", "0.2"} {
+		res = client.Request(&testutil.RequestOption{
+			Method: http.MethodPost,
+			URL:    "/orders",
+			Body: trade.CreateOrder{
+				Symbol:   testSymbol,
+				Type:     models.BUY,
+				Price:    models.MustParseDecimal("110.2"),
+				Quantity: models.MustParseDecimal(quantity),
+			},
+		})
+		assert.Equal(t, http.StatusOK, res.Code)
+		result = trade.PlaceOrderResult{}
+		json.NewDecoder(res.Body).Decode(&result)
+		assert.Len(t, result.Fills, 1)
+		assert.Equal(t, "110.2", result.Fills[0].Price.String())
+		assert.Equal(t, quantity, result.Fills[0].Quantity.String())
+	}
+
+	client.SetUser(1)
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/orders?status=FILLED",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	orders := make([]models.Order, 0)
+	json.NewDecoder(res.Body).Decode(&orders)
+	assert.Len(t, orders, 1)
+	assert.Equal(t, "110.2", orders[0].Price.String())
+	assert.Equal(t, "0", orders[0].Remaining.String())
+}
+
+func Test_DecimalPrice_RejectInvalid(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+
+	for _, price := range []string{"1.0000000000000000001", "abc", "0", "-1"} {
+		res := client.Request(&testutil.RequestOption{
+			Method: http.MethodPost,
+			URL:    "/orders",
+			Body: map[string]interface{}{
+				"symbol":   testSymbol,
+				"type":     models.SELL,
+				"price":    price,
+				"quantity": "1",
+			},
+		})
+		assert.Equal(t, http.StatusBadRequest, res.Code, price)
+	}
+}
+
+func Test_DecimalPrice_RejectBeyondDecimal128(t *testing.T) {
+	t.Setenv("ENV", "test")
+	// Without a tick size, prices may have up to 18 decimal places
+	path := filepath.Join(t.TempDir(), "markets.json")
+	if err := os.WriteFile(path, []byte(`[{"symbol": "BTC-USDT", "base": "BTC", "quote": "USDT"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MARKETS_CONFIG", path)
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1)
+	client.SetUser(1)
+
+	// 36 significant digits fit in 16 bytes, not in a Decimal128
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: map[string]interface{}{
+			"symbol":   testSymbol,
+			"type":     models.SELL,
+			"price":    "123456789012345678.123456789012345671",
+			"quantity": "1",
+		},
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Empty(t, getOrderBook(t, client, "/orderbook?symbol="+testSymbol).Asks)
+
+	placeSell(t, client, "123456789012345678.1234567890123456", "1")
+	assert.Equal(t, "123456789012345678.1234567890123456", listOrders(t, client, "/orders")[0].Price.String())
+}
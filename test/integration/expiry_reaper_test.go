@@ -0,0 +1,160 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/db/rocksdb"
+	"trading-bsx/pkg/events"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/linxGnu/grocksdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func countBookEntries(cf *grocksdb.ColumnFamilyHandle) int {
+	ro := grocksdb.NewDefaultReadOptions()
+	defer ro.Destroy()
+	it := rocksdb.DB.NewIteratorCF(ro, cf)
+	defer it.Close()
+	count := 0
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		count++
+	}
+	return count
+}
+
+func Test_ExpiryReaper_RemovesExpiredOrders(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	stopReaper := trade.StartExpiryReaper()
+	defer stopReaper()
+	eventCh, unsubscribe := events.Subscribe(100)
+	defer unsubscribe()
+
+	client := testutil.NewClient(s)
+
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	// Long enough for the next order to be placed before it expires
+	var gtt uint64 = 500
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal("100"),
+			Quantity: models.MustParseDecimal("1"),
+			GTT:      &gtt,
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+
+	// An order without expiration time is not reaped
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal("101"),
+			Quantity: models.MustParseDecimal("1"),
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	orderBook := rocksdb.Book(testSymbol)
+	assert.Equal(t, 2, countBookEntries(orderBook.SellOrder))
+	assert.Equal(t, 1, countBookEntries(orderBook.Expiry))
+
+	timeout := time.After(2 * time.Second)
+	expired := false
+	for !expired {
+		select {
+		case event := <-eventCh:
+			// Market data events are published too
+			if event.Type != events.ORDER_EXPIRED {
+				continue
+			}
+			expired = true
+			assert.Equal(t, testSymbol, event.Symbol)
+			assert.Equal(t, uint64(1), event.UserId)
+			assert.Equal(t, result.Order.ID.Hex(), event.Data.(models.Order).ID.Hex())
+			assert.GreaterOrEqual(t, event.Timestamp, *result.Order.ExpiredAt)
+		case <-timeout:
+			assert.Fail(t, "order is not expired")
+			return
+		}
+	}
+
+	assert.Equal(t, 1, countBookEntries(orderBook.SellOrder))
+	assert.Equal(t, 0, countBookEntries(orderBook.Expiry))
+	orders := getOrdersByStatus(client, models.EXPIRED)
+	if assert.Len(t, orders, 1) {
+		assert.Equal(t, result.Order.ID.Hex(), orders[0].ID.Hex())
+		lastTransition := orders[0].Transitions[len(orders[0].Transitions)-1]
+		assert.Equal(t, models.EXPIRED, lastTransition.Status)
+	}
+}
+
+func Test_ExpiryReaper_SkipsFilledOrders(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+
+	var gtt uint64 = 1000
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal("100"),
+			Quantity: models.MustParseDecimal("1"),
+			GTT:      &gtt,
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	orderBook := rocksdb.Book(testSymbol)
+	assert.Equal(t, 1, countBookEntries(orderBook.Expiry))
+
+	// Filled & cancelled orders leave the expiry index
+	client.SetUser(2)
+	code, _ := placeBuy(client, "100", "1", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 0, countBookEntries(orderBook.Expiry))
+
+	client.SetUser(1)
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal("100"),
+			Quantity: models.MustParseDecimal("1"),
+			GTT:      &gtt,
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Equal(t, 1, countBookEntries(orderBook.Expiry))
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodDelete,
+		URL:    "/orders/" + result.Order.ID.Hex(),
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, 0, countBookEntries(orderBook.Expiry))
+	assert.Equal(t, 0, countBookEntries(orderBook.SellOrder))
+}
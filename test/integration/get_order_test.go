@@ -0,0 +1,92 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func getOrder(client *testutil.Client, url string) (int, trade.OrderDetail) {
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    url,
+	})
+	detail := trade.OrderDetail{}
+	json.NewDecoder(res.Body).Decode(&detail)
+	return res.Code, detail
+}
+
+func Test_GetOrder_WithFills(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	order := placeSell(t, client, "100", "3")
+
+	client.SetUser(2)
+	code, buy := placeBuy(client, "100", "1", "")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = placeBuy(client, "100", "1", "")
+	assert.Equal(t, http.StatusOK, code)
+
+	client.SetUser(1)
+	code, detail := getOrder(client, "/orders/"+order.ID.Hex())
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, order.ID.Hex(), detail.ID.Hex())
+	assert.Equal(t, models.PARTIALLY_FILLED, detail.Status)
+	assert.Equal(t, "2", detail.Filled.String())
+	assert.Equal(t, "1", detail.Remaining.String())
+	if assert.Len(t, detail.Fills, 2) {
+		assert.Equal(t, buy.Order.ID.Hex(), detail.Fills[0].TakerOrderId.Hex())
+		assert.Less(t, detail.Fills[0].Sequence, detail.Fills[1].Sequence)
+	}
+
+	code, detail = getOrder(client, "/markets/"+testSymbol+"/orders/"+order.ID.Hex())
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, detail.Fills, 2)
+
+	// The taker sees its fill too
+	client.SetUser(2)
+	code, detail = getOrder(client, "/orders/"+buy.Order.ID.Hex())
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, models.FILLED, detail.Status)
+	assert.Equal(t, "1", detail.Filled.String())
+	assert.Len(t, detail.Fills, 1)
+}
+
+func Test_GetOrder_NotFound(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	order := placeSell(t, client, "100", "1")
+
+	code, detail := getOrder(client, "/orders/"+order.ID.Hex())
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "0", detail.Filled.String())
+	assert.Len(t, detail.Fills, 0)
+
+	code, _ = getOrder(client, "/markets/"+otherSymbol+"/orders/"+order.ID.Hex())
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = getOrder(client, "/orders/"+primitive.NewObjectID().Hex())
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = getOrder(client, "/orders/client/unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = getOrder(client, "/orders/invalid")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	client.SetUser(2)
+	code, _ = getOrder(client, "/orders/"+order.ID.Hex())
+	assert.Equal(t, http.StatusNotFound, code)
+}
@@ -0,0 +1,114 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func listOrders(t *testing.T, client *testutil.Client, url string) []models.Order {
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    url,
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	orders := make([]models.Order, 0)
+	json.NewDecoder(res.Body).Decode(&orders)
+	return orders
+}
+
+func orderPrices(orders []models.Order) []string {
+	prices := make([]string, len(orders))
+	for i := range orders {
+		prices[i] = orders[i].Price.String()
+	}
+	return prices
+}
+
+func Test_GetOrders_Pagination(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	for _, price := range []string{"103", "101", "105", "102", "104"} {
+		placeSell(t, client, price, "1")
+	}
+
+	// Latest first by default
+	orders := listOrders(t, client, "/orders?limit=2")
+	assert.Equal(t, []string{"104", "102"}, orderPrices(orders))
+	orders = listOrders(t, client, "/orders?limit=2&after="+orders[1].ID.Hex())
+	assert.Equal(t, []string{"105", "101"}, orderPrices(orders))
+	orders = listOrders(t, client, "/orders?limit=2&after="+orders[1].ID.Hex())
+	assert.Equal(t, []string{"103"}, orderPrices(orders))
+	orders = listOrders(t, client, "/orders?limit=2&after="+orders[0].ID.Hex())
+	assert.Len(t, orders, 0)
+
+	orders = listOrders(t, client, "/orders?sort=price&order=asc&limit=3")
+	assert.Equal(t, []string{"101", "102", "103"}, orderPrices(orders))
+	orders = listOrders(t, client, "/orders?sort=price&order=asc&limit=3&after="+orders[2].ID.Hex())
+	assert.Equal(t, []string{"104", "105"}, orderPrices(orders))
+	orders = listOrders(t, client, "/orders?sort=price")
+	assert.Equal(t, []string{"105", "104", "103", "102", "101"}, orderPrices(orders))
+
+	// Another user's order is not a valid cursor
+	client.SetUser(2)
+	other := placeSell(t, client, "110", "1")
+	client.SetUser(1)
+	for _, query := range []string{
+		"after=" + other.ID.Hex(),
+		"after=" + primitive.NewObjectID().Hex(),
+		"limit=1001",
+		"sort=quantity",
+		"order=up",
+	} {
+		res := client.Request(&testutil.RequestOption{
+			Method: http.MethodGet,
+			URL:    "/orders?" + query,
+		})
+		assert.Equal(t, http.StatusBadRequest, res.Code, query)
+	}
+}
+
+func Test_GetOrders_Filters(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	placeSell(t, client, "101", "1")
+	placeSell(t, client, "102", "1")
+	between := uint64(time.Now().UnixMilli()) + 1
+	time.Sleep(2 * time.Millisecond)
+	placeSell(t, client, "103", "1")
+	code, _ := placeBuy(client, "90", "1", "")
+	assert.Equal(t, http.StatusOK, code)
+
+	orders := listOrders(t, client, "/orders?type=SELL&minPrice=102&maxPrice=103")
+	assert.Equal(t, []string{"103", "102"}, orderPrices(orders))
+	orders = listOrders(t, client, "/orders?type=BUY")
+	assert.Equal(t, []string{"90"}, orderPrices(orders))
+	orders = listOrders(t, client, fmt.Sprintf("/orders?to=%d", between))
+	assert.Equal(t, []string{"102", "101"}, orderPrices(orders))
+	orders = listOrders(t, client, fmt.Sprintf("/orders?from=%d&type=SELL", between))
+	assert.Equal(t, []string{"103"}, orderPrices(orders))
+	orders = listOrders(t, client, "/orders?status=FILLED")
+	assert.Len(t, orders, 0)
+
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    fmt.Sprintf("/orders?from=%d&to=%d", between, between),
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
@@ -0,0 +1,151 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Idempotency_KeyReplaysResult(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	placeSellLevels(t, client, []string{"100"})
+
+	client.SetUser(2)
+	body := trade.CreateOrder{
+		Symbol:   testSymbol,
+		Type:     models.BUY,
+		Price:    models.MustParseDecimal("100"),
+		Quantity: models.MustParseDecimal("2"),
+	}
+	results := make([]trade.PlaceOrderResult, 0)
+	for i := 0; i < 2; i++ {
+		res := client.Request(&testutil.RequestOption{
+			Method:  http.MethodPost,
+			URL:     "/orders",
+			Body:    body,
+			Headers: map[string]string{trade.IDEMPOTENCY_KEY_HEADER: "retry-1"},
+		})
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, i == 1, res.Header().Get(trade.IDEMPOTENT_REPLAYED_HEADER) == "true")
+		result := trade.PlaceOrderResult{}
+		json.NewDecoder(res.Body).Decode(&result)
+		results = append(results, result)
+	}
+	assert.Equal(t, results[0].Order.ID.Hex(), results[1].Order.ID.Hex())
+	assert.Len(t, results[1].Fills, 1)
+	assert.Equal(t, results[0].Fills[0].MakerOrderId.Hex(), results[1].Fills[0].MakerOrderId.Hex())
+	assert.Equal(t, 1, countOpenOrders(t, client))
+
+	// The same key can't be used by another request
+	body.Quantity = models.MustParseDecimal("3")
+	res := client.Request(&testutil.RequestOption{
+		Method:  http.MethodPost,
+		URL:     "/orders",
+		Body:    body,
+		Headers: map[string]string{trade.IDEMPOTENCY_KEY_HEADER: "retry-1"},
+	})
+	assert.Equal(t, http.StatusConflict, res.Code)
+
+	// Keys are scoped per user
+	client.SetUser(3)
+	res = client.Request(&testutil.RequestOption{
+		Method:  http.MethodPost,
+		URL:     "/orders",
+		Body:    body,
+		Headers: map[string]string{trade.IDEMPOTENCY_KEY_HEADER: "retry-1"},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Empty(t, res.Header().Get(trade.IDEMPOTENT_REPLAYED_HEADER))
+}
+
+func Test_Idempotency_ClientOrderId(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+
+	body := trade.CreateOrder{
+		Symbol:        testSymbol,
+		Type:          models.SELL,
+		Price:         models.MustParseDecimal("100"),
+		Quantity:      models.MustParseDecimal("1"),
+		ClientOrderId: "quote_1-a",
+	}
+	orderIds := make([]string, 0)
+	for i := 0; i < 2; i++ {
+		res := client.Request(&testutil.RequestOption{
+			Method: http.MethodPost,
+			URL:    "/orders",
+			Body:   body,
+		})
+		assert.Equal(t, http.StatusOK, res.Code)
+		result := trade.PlaceOrderResult{}
+		json.NewDecoder(res.Body).Decode(&result)
+		assert.Equal(t, "quote_1-a", result.Order.ClientOrderId)
+		orderIds = append(orderIds, result.Order.ID.Hex())
+	}
+	assert.Equal(t, orderIds[0], orderIds[1])
+	assert.Equal(t, 1, countOpenOrders(t, client))
+
+	body.Price = models.MustParseDecimal("101")
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body:   body,
+	})
+	assert.Equal(t, http.StatusConflict, res.Code)
+
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/orders/client/quote_1-a",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	order := models.Order{}
+	json.NewDecoder(res.Body).Decode(&order)
+	assert.Equal(t, orderIds[0], order.ID.Hex())
+
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodDelete,
+		URL:    "/orders/client/quote_1-a",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, 0, countOpenOrders(t, client))
+
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/orders/client/quote_1-a",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	order = models.Order{}
+	json.NewDecoder(res.Body).Decode(&order)
+	assert.Equal(t, models.CANCELLED, order.Status)
+
+	// Client order IDs of other users are not visible
+	client.SetUser(2)
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/orders/client/quote_1-a",
+	})
+	assert.Equal(t, http.StatusNotFound, res.Code)
+
+	body.ClientOrderId = "not valid!"
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body:   body,
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
@@ -0,0 +1,82 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/ledger"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getLedger(t *testing.T, client *testutil.Client, url string) []models.LedgerEntry {
+	res := client.Request(&testutil.RequestOption{Method: http.MethodGet, URL: url})
+	assert.Equal(t, http.StatusOK, res.Code)
+	entries := []models.LedgerEntry{}
+	json.Unmarshal(res.Body.Bytes(), &entries)
+	return entries
+}
+
+func Test_Ledger_RecordsFillsWithFees(t *testing.T) {
+	t.Setenv("ENV", "test")
+	path := filepath.Join(t.TempDir(), "markets.json")
+	config := `[{"symbol": "BTC-USDT", "base": "BTC", "quote": "USDT", "fees": {"maker": "0.001", "taker": "0.002"}}]`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MARKETS_CONFIG", path)
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	deposit(t, 1, "USDT", "1000")
+	deposit(t, 2, "BTC", "10")
+
+	client.SetUser(1)
+	code, _ := placeBuy(client, "100", "2", "")
+	assert.Equal(t, http.StatusOK, code)
+	client.SetUser(2)
+	placeSell(t, client, "100", "2")
+
+	// The maker buyer pays 0.1% of the base, the taker seller 0.2% of the quote
+	available, _ := getBalance(t, client, "USDT")
+	assert.Equal(t, "199.6", available)
+	client.SetUser(1)
+	available, _ = getBalance(t, client, "BTC")
+	assert.Equal(t, "1.998", available)
+
+	client.SetUser(2)
+	entries := getLedger(t, client, "/ledger")
+	assert.Len(t, entries, 4)
+	assert.Equal(t, models.FEE, entries[0].Type)
+	assert.Equal(t, "0.4", entries[0].Debit.String())
+	assert.Equal(t, models.FILL, entries[1].Type)
+	assert.Equal(t, "200", entries[1].Credit.String())
+	assert.Equal(t, testSymbol, entries[1].Symbol)
+	assert.Equal(t, models.FILL, entries[2].Type)
+	assert.Equal(t, "2", entries[2].Debit.String())
+	assert.Equal(t, entries[1].TransactionId, entries[2].TransactionId)
+	assert.Equal(t, models.DEPOSIT, entries[3].Type)
+	assert.Equal(t, "10", entries[3].Credit.String())
+
+	// Filtered by asset & paginated
+	entries = getLedger(t, client, "/ledger?asset=USDT&limit=1")
+	assert.Len(t, entries, 1)
+	assert.Equal(t, models.FEE, entries[0].Type)
+	entries = getLedger(t, client, "/ledger?asset=USDT&after="+strconv.FormatUint(entries[0].Sequence, 10))
+	assert.Len(t, entries, 1)
+	assert.Equal(t, models.FILL, entries[0].Type)
+
+	report, err := ledger.Verify()
+	assert.Nil(t, err)
+	assert.Empty(t, report.Errors)
+	assert.Equal(t, uint64(3), report.Transactions)
+	assert.Equal(t, "0.002", report.SystemAccounts[models.FEES_ACCOUNT]["BTC"].String())
+	assert.Equal(t, "0.4", report.SystemAccounts[models.FEES_ACCOUNT]["USDT"].String())
+	assert.Equal(t, "-10", report.SystemAccounts[models.EXTERNAL_ACCOUNT]["BTC"].String())
+}
@@ -0,0 +1,158 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func placeSellLevels(t *testing.T, client *testutil.Client, prices []string) {
+	for _, price := range prices {
+		res := client.Request(&testutil.RequestOption{
+			Method: http.MethodPost,
+			URL:    "/orders",
+			Body: trade.CreateOrder{
+				Symbol:   testSymbol,
+				Type:     models.SELL,
+				Price:    models.MustParseDecimal(price),
+				Quantity: models.MustParseDecimal("1"),
+			},
+		})
+		assert.Equal(t, http.StatusOK, res.Code)
+	}
+}
+
+func Test_MarketOrder_SweepsBook(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	placeSellLevels(t, client, []string{"100", "105", "110"})
+
+	client.SetUser(2)
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:    testSymbol,
+			Type:      models.BUY,
+			OrderType: models.MARKET,
+			Quantity:  models.MustParseDecimal("2.5"),
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Len(t, result.Fills, 3)
+	assert.Equal(t, "100", result.Fills[0].Price.String())
+	assert.Equal(t, "105", result.Fills[1].Price.String())
+	assert.Equal(t, "110", result.Fills[2].Price.String())
+	assert.Equal(t, "0.5", result.Fills[2].Quantity.String())
+	assert.Equal(t, models.MARKET, result.Order.OrderType)
+	assert.Equal(t, models.FILLED, result.Order.Status)
+}
+
+func Test_MarketOrder_RemainderIsCancelled(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+
+	// Empty book, nothing to match
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:    testSymbol,
+			Type:      models.BUY,
+			OrderType: models.MARKET,
+			Quantity:  models.MustParseDecimal("1"),
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Empty(t, result.Fills)
+	assert.Equal(t, models.CANCELLED, result.Order.Status)
+
+	placeSellLevels(t, client, []string{"100"})
+
+	// The unfilled remainder doesn't rest on the book
+	client.SetUser(2)
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:    testSymbol,
+			Type:      models.BUY,
+			OrderType: models.MARKET,
+			Quantity:  models.MustParseDecimal("3"),
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result = trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Len(t, result.Fills, 1)
+	assert.Equal(t, "2", result.Order.Remaining.String())
+	assert.Equal(t, models.CANCELLED, result.Order.Status)
+
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/orders",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	orders := make([]models.Order, 0)
+	json.NewDecoder(res.Body).Decode(&orders)
+	assert.Len(t, orders, 0)
+}
+
+func Test_MarketOrder_SlippageProtection(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	placeSellLevels(t, client, []string{"100", "105", "110"})
+
+	client.SetUser(2)
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:    testSymbol,
+			Type:      models.BUY,
+			OrderType: models.MARKET,
+			Price:     models.MustParseDecimal("105"),
+			Quantity:  models.MustParseDecimal("3"),
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Len(t, result.Fills, 2)
+	assert.Equal(t, "1", result.Order.Remaining.String())
+	assert.Equal(t, models.CANCELLED, result.Order.Status)
+
+	// A limit order still requires a price
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.BUY,
+			Quantity: models.MustParseDecimal("1"),
+		},
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
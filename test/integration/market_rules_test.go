@@ -0,0 +1,75 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/market"
+	"trading-bsx/pkg/testutil"
+	"trading-bsx/pkg/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MarketRules_Get(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/markets/" + testSymbol + "/rules",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	rules := market.Rules{}
+	json.NewDecoder(res.Body).Decode(&rules)
+	assert.Equal(t, "0.01", rules.TickSize.String())
+	assert.Equal(t, "5", rules.MinNotional.String())
+
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/markets/DOGE-USDT/rules",
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
+
+func Test_MarketRules_RejectOrders(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+
+	var cases = []struct {
+		price    string
+		quantity string
+		rule     string
+	}{
+		{"100.001", "1", "tickSize"},
+		{"100", "0.000001", "minQuantity"},
+		{"100", "2000", "maxQuantity"},
+		{"100", "0.000015", "lotSize"},
+		{"100", "0.01", "minNotional"},
+	}
+	for _, tc := range cases {
+		res := client.Request(&testutil.RequestOption{
+			Method: http.MethodPost,
+			URL:    "/orders",
+			Body: map[string]interface{}{
+				"symbol":   testSymbol,
+				"type":     models.BUY,
+				"price":    tc.price,
+				"quantity": tc.quantity,
+			},
+		})
+		assert.Equal(t, http.StatusBadRequest, res.Code, tc.rule)
+		validationErr := utils.ValidationError{}
+		json.NewDecoder(res.Body).Decode(&validationErr)
+		assert.Equal(t, tc.rule, validationErr.Metadata["rule"])
+	}
+}
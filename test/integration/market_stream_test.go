@@ -0,0 +1,171 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+// streamMessage is an event of the stream, whose data is decoded by the test
+type streamMessage struct {
+	Type     string          `json:"type"`
+	Symbol   string          `json:"symbol"`
+	Sequence uint64          `json:"sequence"`
+	Data     json.RawMessage `json:"data"`
+}
+
+func dialStream(t *testing.T, httpServer *httptest.Server, path string) *websocket.Conn {
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+path, "", httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func receiveMessage(t *testing.T, ws *websocket.Conn) streamMessage {
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	msg := streamMessage{}
+	if err := websocket.JSON.Receive(ws, &msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func Test_MarketStream_BookAndTrades(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	httpServer := httptest.NewServer(s)
+	defer httpServer.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	placeSell(t, client, "101", "1")
+
+	ws := dialStream(t, httpServer, "/ws")
+	defer ws.Close()
+	websocket.JSON.Send(ws, trade.StreamRequest{Op: "subscribe", Channel: "book", Symbol: testSymbol})
+	msg := receiveMessage(t, ws)
+	assert.Equal(t, "BOOK_SNAPSHOT", msg.Type)
+	book := trade.OrderBookDepth{}
+	json.Unmarshal(msg.Data, &book)
+	assert.Equal(t, []string{"101"}, levelPrices(book.Asks))
+	bookSeq := msg.Sequence
+
+	websocket.JSON.Send(ws, trade.StreamRequest{Op: "subscribe", Channel: "trades", Symbol: testSymbol})
+	msg = receiveMessage(t, ws)
+	assert.Equal(t, "SUBSCRIBED", msg.Type)
+	assert.Equal(t, uint64(0), msg.Sequence)
+
+	placeSell(t, client, "101", "2")
+	msg = receiveMessage(t, ws)
+	assert.Equal(t, "BOOK_UPDATE", msg.Type)
+	assert.Equal(t, bookSeq+1, msg.Sequence)
+	update := trade.BookUpdate{}
+	json.Unmarshal(msg.Data, &update)
+	if assert.Len(t, update.Asks, 1) {
+		assert.Equal(t, "3", update.Asks[0].Quantity.String())
+		assert.Equal(t, 2, update.Asks[0].Count)
+	}
+
+	client.SetUser(2)
+	code, _ := placeBuy(client, "101", "3", "")
+	assert.Equal(t, http.StatusOK, code)
+	for i := uint64(1); i <= 2; i++ {
+		msg = receiveMessage(t, ws)
+		assert.Equal(t, "TRADE", msg.Type)
+		assert.Equal(t, i, msg.Sequence)
+	}
+	msg = receiveMessage(t, ws)
+	assert.Equal(t, "BOOK_UPDATE", msg.Type)
+	assert.Equal(t, bookSeq+2, msg.Sequence)
+	update = trade.BookUpdate{}
+	json.Unmarshal(msg.Data, &update)
+	if assert.Len(t, update.Asks, 1) {
+		assert.Equal(t, "0", update.Asks[0].Quantity.String())
+		assert.Equal(t, 0, update.Asks[0].Count)
+	}
+
+	// Unsubscribed channels & other markets are not streamed
+	websocket.JSON.Send(ws, trade.StreamRequest{Op: "unsubscribe", Channel: "trades", Symbol: testSymbol})
+	msg = receiveMessage(t, ws)
+	assert.Equal(t, "UNSUBSCRIBED", msg.Type)
+	websocket.JSON.Send(ws, trade.StreamRequest{Op: "subscribe", Channel: "ticker", Symbol: testSymbol})
+	msg = receiveMessage(t, ws)
+	assert.Equal(t, "SUBSCRIBED", msg.Type)
+	tickerSeq := msg.Sequence
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/markets/" + otherSymbol + "/orders",
+		Body:   map[string]interface{}{"type": "BUY", "price": "10", "quantity": "1"},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	order := placeSell(t, client, "105", "1")
+	client.Request(&testutil.RequestOption{
+		Method: http.MethodDelete,
+		URL:    "/orders/" + order.ID.Hex(),
+	})
+	for _, expected := range []string{"BOOK_UPDATE", "TICKER", "BOOK_UPDATE", "TICKER"} {
+		msg = receiveMessage(t, ws)
+		assert.Equal(t, expected, msg.Type)
+		assert.Equal(t, testSymbol, msg.Symbol)
+	}
+	assert.Equal(t, tickerSeq+2, msg.Sequence)
+	ticker := trade.Ticker{}
+	json.Unmarshal(msg.Data, &ticker)
+	assert.Nil(t, ticker.BestAsk)
+	assert.Equal(t, "101", ticker.LastPrice.String())
+}
+
+func Test_MarketStream_Candles(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	httpServer := httptest.NewServer(s)
+	defer httpServer.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+
+	ws := dialStream(t, httpServer, "/ws")
+	defer ws.Close()
+	for _, req := range []string{
+		`{"op":"subscribe","channel":"candles","symbol":"` + testSymbol + `"}`,
+		`{"op":"subscribe","channel":"book","symbol":"UNKNOWN"}`,
+		`not json`,
+	} {
+		websocket.Message.Send(ws, req)
+		msg := receiveMessage(t, ws)
+		assert.Equal(t, "ERROR", msg.Type, req)
+	}
+
+	websocket.JSON.Send(ws, trade.StreamRequest{Op: "subscribe", Channel: "candles", Symbol: testSymbol, Interval: "1h"})
+	msg := receiveMessage(t, ws)
+	assert.Equal(t, "SUBSCRIBED", msg.Type)
+
+	client.SetUser(1)
+	placeSell(t, client, "100", "1")
+	client.SetUser(2)
+	code, _ := placeBuy(client, "100", "0.5", "")
+	assert.Equal(t, http.StatusOK, code)
+	msg = receiveMessage(t, ws)
+	assert.Equal(t, "CANDLE", msg.Type)
+	assert.Equal(t, uint64(1), msg.Sequence)
+	candle := struct {
+		Interval string `json:"interval"`
+		Close    string `json:"close"`
+		Volume   string `json:"volume"`
+	}{}
+	json.Unmarshal(msg.Data, &candle)
+	assert.Equal(t, "1h", candle.Interval)
+	assert.Equal(t, "100", candle.Close)
+	assert.Equal(t, "0.5", candle.Volume)
+}
@@ -0,0 +1,102 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSymbol = "BTC-USDT"
+const otherSymbol = "ETH-USDT"
+
+func Test_Markets_HaveSeparateBooks(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/markets/" + testSymbol + "/orders",
+		Body: trade.CreateOrder{
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal("100"),
+			Quantity: models.MustParseDecimal("1"),
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	client.SetUser(2)
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/markets/" + otherSymbol + "/orders",
+		Body: trade.CreateOrder{
+			Type:     models.BUY,
+			Price:    models.MustParseDecimal("200"),
+			Quantity: models.MustParseDecimal("1"),
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Empty(t, result.Fills)
+	assert.Equal(t, otherSymbol, result.Order.Symbol)
+
+	client.SetUser(1)
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/markets/" + testSymbol + "/orders",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	orders := make([]models.Order, 0)
+	json.NewDecoder(res.Body).Decode(&orders)
+	assert.Len(t, orders, 1)
+
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/markets/" + otherSymbol + "/orders",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	orders = make([]models.Order, 0)
+	json.NewDecoder(res.Body).Decode(&orders)
+	assert.Len(t, orders, 0)
+}
+
+func Test_Markets_RejectUnlistedSymbol(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/markets/DOGE-USDT/orders",
+		Body: trade.CreateOrder{
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal("100"),
+			Quantity: models.MustParseDecimal("1"),
+		},
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal("100"),
+			Quantity: models.MustParseDecimal("1"),
+		},
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
@@ -21,37 +21,45 @@ func Test_MatchBuyOrder(t *testing.T) {
 	defer s.Close()
 
 	client := testutil.NewClient(s)
+
+	fundUsers(t, 1, 2, 3, 4)
 	client.SetUser(1)
 
-	var prices = []float64{100.5, 110.2, 120.3, 130.4, 140.5, 150.6, 160.7, 170.8, 180.9, 190.0}
+	var prices = []string{"100.5", "110.2", "120.3", "130.4", "140.5", "150.6", "160.7", "170.8", "180.9", "190"}
 	for _, price := range prices {
 		client.Request(&testutil.RequestOption{
 			Method: http.MethodPost,
 			URL:    "/orders",
 			Body: trade.CreateOrder{
-				Type:  models.BUY,
-				Price: price,
+				Symbol:   testSymbol,
+				Type:     models.BUY,
+				Price:    models.MustParseDecimal(price),
+				Quantity: models.MustParseDecimal("1"),
 			},
 		})
 	}
 
-	var matchPrices = []float64{190.0, 180.9}
+	var matchPrices = []string{"190", "180.9"}
 	client.SetUser(2)
 	for _, matchPrice := range matchPrices {
 		res := client.Request(&testutil.RequestOption{
 			Method: http.MethodPost,
 			URL:    "/orders",
 			Body: trade.CreateOrder{
-				Type:  models.SELL,
-				Price: 100.0,
+				Symbol:   testSymbol,
+				Type:     models.SELL,
+				Price:    models.MustParseDecimal("100"),
+				Quantity: models.MustParseDecimal("1"),
 			},
 		})
 		assert.Equal(t, http.StatusOK, res.Code)
-		matchedOrder := models.Order{}
-		err := json.NewDecoder(res.Body).Decode(&matchedOrder)
+		result := trade.PlaceOrderResult{}
+		err := json.NewDecoder(res.Body).Decode(&result)
 		assert.NoError(t, err)
 
-		assert.Equal(t, matchPrice, matchedOrder.Price)
+		assert.Len(t, result.Fills, 1)
+		assert.Equal(t, matchPrice, result.Fills[0].Price.String())
+		assert.Equal(t, "0", result.Order.Remaining.String())
 	}
 
 	// Check order unmatched
@@ -59,17 +67,28 @@ func Test_MatchBuyOrder(t *testing.T) {
 		Method: http.MethodPost,
 		URL:    "/orders",
 		Body: trade.CreateOrder{
-			Type:  models.SELL,
-			Price: 500.0,
+			Symbol:   testSymbol,
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal("500"),
+			Quantity: models.MustParseDecimal("1"),
 		},
 	})
 	assert.Equal(t, http.StatusOK, res.Code)
-	orderId := res.Body.String()
-	assert.Len(t, orderId, 24)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Empty(t, result.Fills)
+	assert.NotNil(t, result.Order.ID)
 
-	numOfOrders, err := mongodb.Order.CountDocuments(context.Background(), bson.D{})
+	numOfOrders, err := mongodb.Order.CountDocuments(context.Background(), bson.M{
+		"status": bson.M{"$in": models.OpenStatuses},
+	})
 	assert.NoError(t, err)
 	assert.Equal(t, len(prices)-len(matchPrices)+1, int(numOfOrders))
+
+	// Matched orders are kept as filled
+	numOfOrders, err = mongodb.Order.CountDocuments(context.Background(), bson.M{"status": models.FILLED})
+	assert.NoError(t, err)
+	assert.Equal(t, 2*len(matchPrices), int(numOfOrders))
 }
 
 func Test_MatchSellOrder(t *testing.T) {
@@ -77,37 +96,44 @@ func Test_MatchSellOrder(t *testing.T) {
 	s := server.New()
 	defer s.Close()
 	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
 	client.SetUser(1)
 
-	var prices = []float64{100.5, 110.2, 120.3, 130.4, 140.5, 150.6, 160.7, 170.8, 180.9, 190.0}
+	var prices = []string{"100.5", "110.2", "120.3", "130.4", "140.5", "150.6", "160.7", "170.8", "180.9", "190"}
 	for _, price := range prices {
 		client.Request(&testutil.RequestOption{
 			Method: http.MethodPost,
 			URL:    "/orders",
 			Body: trade.CreateOrder{
-				Type:  models.SELL,
-				Price: price,
+				Symbol:   testSymbol,
+				Type:     models.SELL,
+				Price:    models.MustParseDecimal(price),
+				Quantity: models.MustParseDecimal("1"),
 			},
 		})
 	}
 
-	var matchPrices = []float64{100.5, 110.2}
+	var matchPrices = []string{"100.5", "110.2"}
 	client.SetUser(2)
 	for _, matchPrice := range matchPrices {
 		res := client.Request(&testutil.RequestOption{
 			Method: http.MethodPost,
 			URL:    "/orders",
 			Body: trade.CreateOrder{
-				Type:  models.BUY,
-				Price: 140.0,
+				Symbol:   testSymbol,
+				Type:     models.BUY,
+				Price:    models.MustParseDecimal("140"),
+				Quantity: models.MustParseDecimal("1"),
 			},
 		})
 		assert.Equal(t, http.StatusOK, res.Code)
-		matchedOrder := models.Order{}
-		err := json.NewDecoder(res.Body).Decode(&matchedOrder)
+		result := trade.PlaceOrderResult{}
+		err := json.NewDecoder(res.Body).Decode(&result)
 		assert.NoError(t, err)
 
-		assert.Equal(t, matchPrice, matchedOrder.Price)
+		assert.Len(t, result.Fills, 1)
+		assert.Equal(t, matchPrice, result.Fills[0].Price.String())
+		assert.Equal(t, "0", result.Order.Remaining.String())
 	}
 
 	// Check order unmatched
@@ -115,16 +141,27 @@ func Test_MatchSellOrder(t *testing.T) {
 		Method: http.MethodPost,
 		URL:    "/orders",
 		Body: trade.CreateOrder{
-			Type:  models.BUY,
-			Price: 50.0,
+			Symbol:   testSymbol,
+			Type:     models.BUY,
+			Price:    models.MustParseDecimal("50"),
+			Quantity: models.MustParseDecimal("1"),
 		},
 	})
 	assert.Equal(t, http.StatusOK, res.Code)
-	orderId := res.Body.String()
-	assert.Len(t, orderId, 24)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Empty(t, result.Fills)
+	assert.NotNil(t, result.Order.ID)
 
 	// Check total number of orders after matching
-	numOfOrders, err := mongodb.Order.CountDocuments(context.Background(), bson.D{})
+	numOfOrders, err := mongodb.Order.CountDocuments(context.Background(), bson.M{
+		"status": bson.M{"$in": models.OpenStatuses},
+	})
 	assert.NoError(t, err)
 	assert.Equal(t, len(prices)-len(matchPrices)+1, int(numOfOrders))
+
+	// Matched orders are kept as filled
+	numOfOrders, err = mongodb.Order.CountDocuments(context.Background(), bson.M{"status": models.FILLED})
+	assert.NoError(t, err)
+	assert.Equal(t, 2*len(matchPrices), int(numOfOrders))
 }
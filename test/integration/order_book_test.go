@@ -0,0 +1,101 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getOrderBook(t *testing.T, client *testutil.Client, url string) trade.OrderBookDepth {
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    url,
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	book := trade.OrderBookDepth{}
+	json.NewDecoder(res.Body).Decode(&book)
+	return book
+}
+
+func levelPrices(levels []trade.PriceLevel) []string {
+	prices := make([]string, len(levels))
+	for i := range levels {
+		prices[i] = levels[i].Price.String()
+	}
+	return prices
+}
+
+func Test_OrderBook_Levels(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	placeSell(t, client, "101", "1")
+	placeSell(t, client, "102", "2")
+	placeSell(t, client, "103", "1")
+	for _, price := range []string{"98", "99", "98"} {
+		code, _ := placeBuy(client, price, "1.5", "")
+		assert.Equal(t, http.StatusOK, code)
+	}
+	client.SetUser(2)
+	placeSell(t, client, "101", "0.5")
+	var gtt uint64 = 1
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal("100.5"),
+			Quantity: models.MustParseDecimal("1"),
+			GTT:      &gtt,
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	time.Sleep(5 * time.Millisecond)
+
+	book := getOrderBook(t, client, "/orderbook?symbol="+testSymbol)
+	assert.Equal(t, testSymbol, book.Symbol)
+	assert.Equal(t, []string{"99", "98"}, levelPrices(book.Bids))
+	// The expired order at 100.5 is skipped
+	assert.Equal(t, []string{"101", "102", "103"}, levelPrices(book.Asks))
+	assert.Equal(t, "1.5", book.Asks[0].Quantity.String())
+	assert.Equal(t, 2, book.Asks[0].Count)
+	assert.Equal(t, "3", book.Bids[1].Quantity.String())
+	assert.Equal(t, 2, book.Bids[1].Count)
+	assert.Nil(t, book.Asks[0].Orders)
+
+	book = getOrderBook(t, client, "/markets/"+testSymbol+"/orderbook?depth=1&level=3")
+	assert.Equal(t, []string{"99"}, levelPrices(book.Bids))
+	assert.Equal(t, []string{"101"}, levelPrices(book.Asks))
+	if assert.Len(t, book.Asks[0].Orders, 2) {
+		assert.Equal(t, "1", book.Asks[0].Orders[0].Quantity.String())
+		assert.Equal(t, "0.5", book.Asks[0].Orders[1].Quantity.String())
+		assert.Less(t, book.Asks[0].Orders[0].Timestamp, book.Asks[0].Orders[1].Timestamp)
+	}
+
+	book = getOrderBook(t, client, "/orderbook?symbol="+otherSymbol)
+	assert.Len(t, book.Bids, 0)
+	assert.Len(t, book.Asks, 0)
+
+	for _, url := range []string{
+		"/orderbook",
+		"/orderbook?symbol=" + testSymbol + "&depth=1001",
+		"/orderbook?symbol=" + testSymbol + "&level=1",
+	} {
+		res := client.Request(&testutil.RequestOption{
+			Method: http.MethodGet,
+			URL:    url,
+		})
+		assert.Equal(t, http.StatusBadRequest, res.Code, url)
+	}
+}
@@ -1,6 +1,7 @@
 package engine_test
 
 import (
+	"encoding/json"
 	"net/http"
 	"strings"
 	"testing"
@@ -18,15 +19,18 @@ func Test_ExpiredOrder_ShouldNotMatch(t *testing.T) {
 	s := server.New()
 	defer s.Close()
 	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
 	client.SetUser(1)
 	var gtt uint64 = 10
 	client.Request(&testutil.RequestOption{
 		Method: http.MethodPost,
 		URL:    "/orders",
 		Body: trade.CreateOrder{
-			Type:  models.SELL,
-			Price: 100.0,
-			GTT:   &gtt,
+			Symbol:   testSymbol,
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal("100"),
+			Quantity: models.MustParseDecimal("1"),
+			GTT:      &gtt,
 		},
 	})
 
@@ -36,15 +40,19 @@ func Test_ExpiredOrder_ShouldNotMatch(t *testing.T) {
 		Method: http.MethodPost,
 		URL:    "/orders",
 		Body: trade.CreateOrder{
-			Type:  models.BUY,
-			Price: 101.0,
+			Symbol:   testSymbol,
+			Type:     models.BUY,
+			Price:    models.MustParseDecimal("101"),
+			Quantity: models.MustParseDecimal("1"),
 		},
 	})
 
 	// Check order unmatched
 	assert.Equal(t, http.StatusOK, res.Code)
-	orderId := res.Body.String()
-	assert.Len(t, orderId, 24)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Empty(t, result.Fills)
+	assert.NotNil(t, result.Order.ID)
 
 	// Check total number of orders. User 1's order is expired, so num of his orders should be 0
 	client.SetUser(1)
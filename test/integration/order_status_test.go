@@ -0,0 +1,111 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getOrdersByStatus(client *testutil.Client, status models.OrderStatus) []models.Order {
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    fmt.Sprintf("/orders?status=%s", status),
+	})
+	orders := make([]models.Order, 0)
+	json.NewDecoder(res.Body).Decode(&orders)
+	return orders
+}
+
+func Test_OrderStatus_Lifecycle(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+
+	var gtt uint64 = 10
+	var orderIds []string
+	for _, price := range []string{"100", "101", "102"} {
+		body := trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal(price),
+			Quantity: models.MustParseDecimal("2"),
+		}
+		if price == "102" {
+			body.GTT = &gtt
+		}
+		res := client.Request(&testutil.RequestOption{
+			Method: http.MethodPost,
+			URL:    "/orders",
+			Body:   body,
+		})
+		assert.Equal(t, http.StatusOK, res.Code)
+		result := trade.PlaceOrderResult{}
+		json.NewDecoder(res.Body).Decode(&result)
+		assert.Equal(t, models.NEW, result.Order.Status)
+		orderIds = append(orderIds, result.Order.ID.Hex())
+	}
+
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodDelete,
+		URL:    fmt.Sprintf("/orders/%s", orderIds[1]),
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	// A cancelled order can't be cancelled again
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodDelete,
+		URL:    fmt.Sprintf("/orders/%s", orderIds[1]),
+	})
+	assert.Equal(t, http.StatusNotFound, res.Code)
+
+	time.Sleep(time.Duration(gtt) * time.Millisecond)
+	client.SetUser(2)
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.BUY,
+			Price:    models.MustParseDecimal("110"),
+			Quantity: models.MustParseDecimal("3"),
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Len(t, result.Fills, 1)
+	assert.Equal(t, models.PARTIALLY_FILLED, result.Order.Status)
+	assert.Len(t, result.Order.Transitions, 2)
+
+	client.SetUser(1)
+	filled := getOrdersByStatus(client, models.FILLED)
+	assert.Len(t, filled, 1)
+	assert.Equal(t, orderIds[0], filled[0].ID.Hex())
+	assert.Equal(t, models.NEW, filled[0].Transitions[0].Status)
+	assert.Equal(t, models.FILLED, filled[0].Transitions[1].Status)
+
+	cancelled := getOrdersByStatus(client, models.CANCELLED)
+	assert.Len(t, cancelled, 1)
+	assert.Equal(t, orderIds[1], cancelled[0].ID.Hex())
+
+	expired := getOrdersByStatus(client, models.EXPIRED)
+	assert.Len(t, expired, 1)
+	assert.Equal(t, orderIds[2], expired[0].ID.Hex())
+	assert.Equal(t, models.EXPIRED, expired[0].Status)
+
+	assert.Len(t, getOrdersByStatus(client, models.NEW), 0)
+
+	client.SetUser(2)
+	assert.Len(t, getOrdersByStatus(client, models.PARTIALLY_FILLED), 1)
+}
@@ -0,0 +1,113 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PartialFill_AcrossPriceLevels(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+
+	var prices = []string{"100", "101", "102"}
+	var quantities = []string{"1", "2", "3"}
+	for i, price := range prices {
+		res := client.Request(&testutil.RequestOption{
+			Method: http.MethodPost,
+			URL:    "/orders",
+			Body: trade.CreateOrder{
+				Symbol:   testSymbol,
+				Type:     models.SELL,
+				Price:    models.MustParseDecimal(price),
+				Quantity: models.MustParseDecimal(quantities[i]),
+			},
+		})
+		assert.Equal(t, http.StatusOK, res.Code)
+	}
+
+	// Sweep the first two levels, the remainder rests on the book
+	client.SetUser(2)
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.BUY,
+			Price:    models.MustParseDecimal("101.5"),
+			Quantity: models.MustParseDecimal("4"),
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result := trade.PlaceOrderResult{}
+	err := json.NewDecoder(res.Body).Decode(&result)
+	assert.NoError(t, err)
+	assert.Len(t, result.Fills, 2)
+	assert.Equal(t, "100", result.Fills[0].Price.String())
+	assert.Equal(t, "1", result.Fills[0].Quantity.String())
+	assert.Equal(t, "101", result.Fills[1].Price.String())
+	assert.Equal(t, "2", result.Fills[1].Quantity.String())
+	assert.Equal(t, "1", result.Order.Remaining.String())
+	assert.NotNil(t, result.Order.ID)
+
+	// Partially fill the last level
+	client.SetUser(3)
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.BUY,
+			Price:    models.MustParseDecimal("102"),
+			Quantity: models.MustParseDecimal("2"),
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result = trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Len(t, result.Fills, 1)
+	assert.Equal(t, "102", result.Fills[0].Price.String())
+	assert.Equal(t, "2", result.Fills[0].Quantity.String())
+	assert.Equal(t, "0", result.Order.Remaining.String())
+
+	client.SetUser(1)
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/orders",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	orders := make([]models.Order, 0)
+	json.NewDecoder(res.Body).Decode(&orders)
+	assert.Len(t, orders, 1)
+	assert.Equal(t, "3", orders[0].Quantity.String())
+	assert.Equal(t, "1", orders[0].Remaining.String())
+
+	// The resting remainder keeps matching with its reduced size
+	client.SetUser(4)
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.BUY,
+			Price:    models.MustParseDecimal("110"),
+			Quantity: models.MustParseDecimal("5"),
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result = trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Len(t, result.Fills, 1)
+	assert.Equal(t, "1", result.Fills[0].Quantity.String())
+	assert.Equal(t, "4", result.Order.Remaining.String())
+}
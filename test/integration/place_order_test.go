@@ -18,7 +18,9 @@ func Test_PlaceOrders(t *testing.T) {
 	defer s.Close()
 
 	client := testutil.NewClient(s)
-	var prices = []float64{100.5, 110.2, 120.3, 130.4, 140.5, 150.6, 160.7, 170.8, 180.9, 190.0}
+
+	fundUsers(t, 1, 2, 3, 4)
+	var prices = []string{"100.5", "110.2", "120.3", "130.4", "140.5", "150.6", "160.7", "170.8", "180.9", "190"}
 
 	for _, price := range prices {
 		for j := 1; j <= 2; j++ {
@@ -27,8 +29,10 @@ func Test_PlaceOrders(t *testing.T) {
 				Method: http.MethodPost,
 				URL:    "/orders",
 				Body: trade.CreateOrder{
-					Type:  models.BUY,
-					Price: price,
+					Symbol:   testSymbol,
+					Type:     models.BUY,
+					Price:    models.MustParseDecimal(price),
+					Quantity: models.MustParseDecimal("1"),
 				},
 			})
 			assert.Equal(t, http.StatusOK, res.Code)
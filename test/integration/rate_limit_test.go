@@ -0,0 +1,168 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+	"trading-bsx/pkg/utils"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func setRateLimits(t *testing.T, config string) {
+	path := filepath.Join(t.TempDir(), "rate_limits.json")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RATE_LIMITS_CONFIG", path)
+}
+
+func Test_RateLimit_PerUser(t *testing.T) {
+	t.Setenv("ENV", "test")
+	setRateLimits(t, `{"user": {"capacity": 4, "rate": 0.001}, "weights": {"place": 2, "cancel": 1, "query": 1}}`)
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+
+	client.SetUser(1)
+	res := client.Request(&testutil.RequestOption{Method: http.MethodGet, URL: "/orders"})
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "4", res.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "3", res.Header().Get("RateLimit-Remaining"))
+
+	placeSell(t, client, "100", "1")
+	res = client.Request(&testutil.RequestOption{Method: http.MethodGet, URL: "/orders"})
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "0", res.Header().Get("RateLimit-Remaining"))
+
+	res = client.Request(&testutil.RequestOption{Method: http.MethodGet, URL: "/orders"})
+	assert.Equal(t, http.StatusTooManyRequests, res.Code)
+	assert.NotEmpty(t, res.Header().Get("Retry-After"))
+	errRes := utils.ErrResponse{}
+	json.Unmarshal(res.Body.Bytes(), &errRes)
+	assert.Equal(t, "Rate limit exceeded", errRes.Message)
+	assert.Equal(t, "user", errRes.Metadata.(map[string]interface{})["scope"])
+
+	// Other users have their own bucket
+	client.SetUser(2)
+	res = client.Request(&testutil.RequestOption{Method: http.MethodGet, URL: "/orders"})
+	assert.Equal(t, http.StatusOK, res.Code)
+}
+
+func Test_RateLimit_PerIP(t *testing.T) {
+	t.Setenv("ENV", "test")
+	setRateLimits(t, `{"ip": {"capacity": 3, "rate": 0.001}}`)
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+
+	// Public endpoints are limited too
+	for i := 0; i < 3; i++ {
+		res := client.Request(&testutil.RequestOption{Method: http.MethodGet, URL: "/orderbook?symbol=" + testSymbol})
+		assert.Equal(t, http.StatusOK, res.Code)
+	}
+	res := client.Request(&testutil.RequestOption{Method: http.MethodGet, URL: "/orderbook?symbol=" + testSymbol})
+	assert.Equal(t, http.StatusTooManyRequests, res.Code)
+	errRes := utils.ErrResponse{}
+	json.Unmarshal(res.Body.Bytes(), &errRes)
+	assert.Equal(t, "ip", errRes.Metadata.(map[string]interface{})["scope"])
+}
+
+func Test_RateLimit_PerIPIgnoresSpoofedHeaders(t *testing.T) {
+	t.Setenv("ENV", "test")
+	setRateLimits(t, `{"ip": {"capacity": 3, "rate": 0.001}}`)
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+
+	// Forwarded IPs of an untrusted peer share its bucket
+	codes := make([]int, 0, 4)
+	for i := 0; i < 4; i++ {
+		res := client.Request(&testutil.RequestOption{
+			Method: http.MethodGet,
+			URL:    "/orderbook?symbol=" + testSymbol,
+			Headers: map[string]string{
+				echo.HeaderXForwardedFor: fmt.Sprintf("203.0.113.%d", i),
+				echo.HeaderXRealIP:       fmt.Sprintf("198.51.100.%d", i),
+			},
+		})
+		codes = append(codes, res.Code)
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+}
+
+func Test_RateLimit_PerIPBehindTrustedProxy(t *testing.T) {
+	t.Setenv("ENV", "test")
+	setRateLimits(t, `{"ip": {"capacity": 1, "rate": 0.001}}`)
+	// The peer address of test requests
+	t.Setenv("TRUSTED_PROXIES", "192.0.2.0/24")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+
+	request := func(ip string) int {
+		return client.Request(&testutil.RequestOption{
+			Method:  http.MethodGet,
+			URL:     "/orderbook?symbol=" + testSymbol,
+			Headers: map[string]string{echo.HeaderXForwardedFor: ip},
+		}).Code
+	}
+	assert.Equal(t, http.StatusOK, request("203.0.113.1"))
+	assert.Equal(t, http.StatusTooManyRequests, request("203.0.113.1"))
+	assert.Equal(t, http.StatusOK, request("203.0.113.2"))
+}
+
+func Test_RateLimit_MaxOpenOrders(t *testing.T) {
+	t.Setenv("ENV", "test")
+	setRateLimits(t, `{"maxOpenOrders": 2}`)
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+
+	client.SetUser(1)
+	placeSell(t, client, "100", "1")
+	placeSell(t, client, "101", "1")
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal("102"),
+			Quantity: models.MustParseDecimal("1"),
+		},
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Contains(t, res.Body.String(), "maxOpenOrders")
+
+	// Orders which never rest are allowed, and filled orders free a slot
+	client.SetUser(2)
+	placeSell(t, client, "90", "1")
+	client.SetUser(1)
+	code, _ := placeBuy(client, "90", "1", models.IOC)
+	assert.Equal(t, http.StatusOK, code)
+	client.SetUser(2)
+	code, _ = placeBuy(client, "100", "1", "")
+	assert.Equal(t, http.StatusOK, code)
+	client.SetUser(1)
+	rest := placeSell(t, client, "102", "1")
+
+	// So do cancelled orders
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodDelete,
+		URL:    "/orders/" + rest.ID.Hex(),
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	placeSell(t, client, "103", "1")
+}
@@ -0,0 +1,149 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupSelfTrade places a sell order of user 1 at 100 & a sell order of user 2 at 101.
+// It returns the ID of user 1's order.
+func setupSelfTrade(t *testing.T, client *testutil.Client) string {
+	client.SetUser(2)
+	placeSellLevels(t, client, []string{"101"})
+	client.SetUser(1)
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.SELL,
+			Price:    models.MustParseDecimal("100"),
+			Quantity: models.MustParseDecimal("1"),
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	return result.Order.ID.Hex()
+}
+
+func placeSelfTradeBuy(t *testing.T, client *testutil.Client, mode models.STPMode) trade.PlaceOrderResult {
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:              testSymbol,
+			Type:                models.BUY,
+			Price:               models.MustParseDecimal("101"),
+			Quantity:            models.MustParseDecimal("2"),
+			SelfTradePrevention: mode,
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	return result
+}
+
+func Test_SelfTrade_Modes(t *testing.T) {
+	testCases := []struct {
+		mode           models.STPMode
+		fillPrices     []string
+		status         models.OrderStatus
+		remaining      string
+		selfTrades     int
+		makerStatus    models.OrderStatus
+		makerCancelled bool
+	}{
+		{models.CANCEL_NEWEST, []string{}, models.CANCELLED, "2", 1, models.NEW, false},
+		{models.CANCEL_OLDEST, []string{"101"}, models.PARTIALLY_FILLED, "1", 1, models.CANCELLED, true},
+		{models.CANCEL_BOTH, []string{}, models.CANCELLED, "2", 1, models.CANCELLED, true},
+		{models.DECREMENT_AND_CANCEL, []string{"101"}, models.FILLED, "0", 1, models.CANCELLED, true},
+		{models.ALLOW_SELF_TRADE, []string{"100", "101"}, models.FILLED, "0", 0, models.FILLED, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			t.Setenv("ENV", "test")
+			s := server.New()
+			defer s.Close()
+			client := testutil.NewClient(s)
+			fundUsers(t, 1, 2, 3, 4)
+			makerOrderId := setupSelfTrade(t, client)
+
+			result := placeSelfTradeBuy(t, client, tc.mode)
+			assert.Equal(t, tc.mode, result.Order.SelfTradePrevention)
+			assert.Equal(t, tc.status, result.Order.Status)
+			assert.Equal(t, tc.remaining, result.Order.Remaining.String())
+			assert.Len(t, result.Fills, len(tc.fillPrices))
+			for i, price := range tc.fillPrices {
+				assert.Equal(t, price, result.Fills[i].Price.String())
+			}
+			assert.Len(t, result.SelfTrades, tc.selfTrades)
+			if tc.selfTrades > 0 {
+				assert.Equal(t, tc.mode, result.SelfTrades[0].Mode)
+				assert.Equal(t, makerOrderId, result.SelfTrades[0].MakerOrderId.Hex())
+				assert.Equal(t, tc.mode, result.Order.SelfTradeAction)
+			}
+
+			// The resting order of the same user
+			makerOrders := getOrdersByStatus(client, tc.makerStatus)
+			var makerOrder *models.Order
+			for i := range makerOrders {
+				if makerOrders[i].ID.Hex() == makerOrderId {
+					makerOrder = &makerOrders[i]
+				}
+			}
+			if assert.NotNil(t, makerOrder) && tc.makerCancelled {
+				assert.Equal(t, tc.mode, makerOrder.SelfTradeAction)
+			}
+		})
+	}
+}
+
+func Test_SelfTrade_AccountDefault(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	setupSelfTrade(t, client)
+
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/account",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	account := models.Account{}
+	json.NewDecoder(res.Body).Decode(&account)
+	assert.Equal(t, models.CANCEL_NEWEST, account.SelfTradePrevention)
+
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodPatch,
+		URL:    "/account",
+		Body:   map[string]interface{}{"selfTradePrevention": "CANCEL_OLDEST"},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	account = models.Account{}
+	json.NewDecoder(res.Body).Decode(&account)
+	assert.Equal(t, models.CANCEL_OLDEST, account.SelfTradePrevention)
+
+	result := placeSelfTradeBuy(t, client, "")
+	assert.Equal(t, models.CANCEL_OLDEST, result.Order.SelfTradePrevention)
+	assert.Len(t, result.Fills, 1)
+	assert.Equal(t, "101", result.Fills[0].Price.String())
+
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodPatch,
+		URL:    "/account",
+		Body:   map[string]interface{}{"selfTradePrevention": "SKIP"},
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
@@ -0,0 +1,89 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTicker(t *testing.T, client *testutil.Client, url string) trade.Ticker {
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    url,
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	ticker := trade.Ticker{}
+	json.NewDecoder(res.Body).Decode(&ticker)
+	return ticker
+}
+
+func Test_Ticker_TopOfBookAndStats(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+
+	ticker := getTicker(t, client, "/ticker?symbol="+testSymbol)
+	assert.Equal(t, testSymbol, ticker.Symbol)
+	assert.Nil(t, ticker.BestBid)
+	assert.Nil(t, ticker.LastPrice)
+	assert.Nil(t, ticker.Open)
+	assert.Equal(t, "0", ticker.Volume.String())
+
+	client.SetUser(1)
+	placeSell(t, client, "100", "1")
+	placeSell(t, client, "104", "1")
+	placeSell(t, client, "106", "2")
+	client.SetUser(2)
+	for _, price := range []string{"100", "104", "106"} {
+		code, _ := placeBuy(client, price, "0.5", "")
+		assert.Equal(t, http.StatusOK, code)
+	}
+	code, _ := placeBuy(client, "90", "3", "")
+	assert.Equal(t, http.StatusOK, code)
+	// Fills 100, 100 & 104, then sells to the best bid at 90
+	client.SetUser(3)
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: map[string]interface{}{
+			"symbol":    testSymbol,
+			"type":      "SELL",
+			"orderType": "MARKET",
+			"quantity":  "1",
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	ticker = getTicker(t, client, "/markets/"+testSymbol+"/ticker")
+	assert.Equal(t, "90", ticker.BestBid.String())
+	assert.Equal(t, "2", ticker.BestBidQuantity.String())
+	assert.Equal(t, "104", ticker.BestAsk.String())
+	assert.Equal(t, "0.5", ticker.BestAskQuantity.String())
+	assert.Equal(t, "14", ticker.Spread.String())
+	assert.Equal(t, "90", ticker.LastPrice.String())
+	assert.Equal(t, "100", ticker.Open.String())
+	assert.Equal(t, "104", ticker.High.String())
+	assert.Equal(t, "90", ticker.Low.String())
+	assert.Equal(t, "90", ticker.Close.String())
+	assert.Equal(t, "2.5", ticker.Volume.String())
+	assert.Equal(t, "242", ticker.QuoteVolume.String())
+	assert.Equal(t, "-10", ticker.Change.String())
+	assert.Equal(t, "-10", ticker.ChangePercent.String())
+
+	// Other markets have their own statistics
+	ticker = getTicker(t, client, "/ticker?symbol="+otherSymbol)
+	assert.Nil(t, ticker.LastPrice)
+
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/ticker",
+	})
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
@@ -0,0 +1,160 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func placeBuy(client *testutil.Client, price string, quantity string, tif models.TimeInForce) (int, trade.PlaceOrderResult) {
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:      testSymbol,
+			Type:        models.BUY,
+			Price:       models.MustParseDecimal(price),
+			Quantity:    models.MustParseDecimal(quantity),
+			TimeInForce: tif,
+		},
+	})
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	return res.Code, result
+}
+
+func countOpenOrders(t *testing.T, client *testutil.Client) int {
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/orders",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	orders := make([]models.Order, 0)
+	json.NewDecoder(res.Body).Decode(&orders)
+	return len(orders)
+}
+
+func Test_TimeInForce_IOC(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	placeSellLevels(t, client, []string{"100", "110"})
+
+	client.SetUser(2)
+	code, result := placeBuy(client, "105", "2", models.IOC)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, result.Fills, 1)
+	assert.Equal(t, "1", result.Order.Remaining.String())
+	assert.Equal(t, models.IOC, result.Order.TimeInForce)
+	assert.Equal(t, models.CANCELLED, result.Order.Status)
+	assert.Equal(t, 0, countOpenOrders(t, client))
+}
+
+func Test_TimeInForce_FOK(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	placeSellLevels(t, client, []string{"100", "110"})
+
+	// Only 1 is available at 105, nothing is filled
+	client.SetUser(2)
+	code, result := placeBuy(client, "105", "2", models.FOK)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, result.Fills)
+	assert.Equal(t, "2", result.Order.Remaining.String())
+	assert.Equal(t, models.CANCELLED, result.Order.Status)
+
+	code, result = placeBuy(client, "110", "2", models.FOK)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, result.Fills, 2)
+	assert.Equal(t, models.FILLED, result.Order.Status)
+}
+
+func Test_TimeInForce_PostOnly(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+	placeSellLevels(t, client, []string{"100"})
+
+	client.SetUser(2)
+	code, result := placeBuy(client, "100", "1", models.POST_ONLY)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, result.Fills)
+	assert.Equal(t, models.REJECTED, result.Order.Status)
+	assert.Equal(t, 0, countOpenOrders(t, client))
+
+	code, result = placeBuy(client, "99", "1", models.POST_ONLY)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, models.NEW, result.Order.Status)
+	assert.Equal(t, 1, countOpenOrders(t, client))
+
+	// The resting sell order is untouched by the rejected order
+	client.SetUser(1)
+	assert.Equal(t, 1, countOpenOrders(t, client))
+}
+
+func Test_TimeInForce_GTD(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+
+	expireTime := uint64(time.Now().Add(20 * time.Millisecond).UnixMilli())
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:      testSymbol,
+			Type:        models.SELL,
+			Price:       models.MustParseDecimal("100"),
+			Quantity:    models.MustParseDecimal("1"),
+			TimeInForce: models.GTD,
+			ExpireTime:  &expireTime,
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Equal(t, models.GTD, result.Order.TimeInForce)
+	assert.Equal(t, expireTime*uint64(time.Millisecond), *result.Order.ExpiredAt)
+	assert.Equal(t, 1, countOpenOrders(t, client))
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, 0, countOpenOrders(t, client))
+
+	// GTD requires an expire time in the future
+	for _, body := range []trade.CreateOrder{
+		{TimeInForce: models.GTD},
+		{TimeInForce: models.GTD, ExpireTime: &expireTime},
+		{TimeInForce: models.GTC, ExpireTime: &expireTime},
+	} {
+		body.Symbol = testSymbol
+		body.Type = models.SELL
+		body.Price = models.MustParseDecimal("100")
+		body.Quantity = models.MustParseDecimal("1")
+		res = client.Request(&testutil.RequestOption{
+			Method: http.MethodPost,
+			URL:    "/orders",
+			Body:   body,
+		})
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+	}
+}
@@ -0,0 +1,98 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/internal/trade"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TradeHistory(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+	client.SetUser(1)
+
+	var prices = []string{"100", "101"}
+	makerOrderIds := make([]string, 0)
+	for _, price := range prices {
+		res := client.Request(&testutil.RequestOption{
+			Method: http.MethodPost,
+			URL:    "/orders",
+			Body: trade.CreateOrder{
+				Symbol:   testSymbol,
+				Type:     models.SELL,
+				Price:    models.MustParseDecimal(price),
+				Quantity: models.MustParseDecimal("1"),
+			},
+		})
+		assert.Equal(t, http.StatusOK, res.Code)
+		result := trade.PlaceOrderResult{}
+		json.NewDecoder(res.Body).Decode(&result)
+		makerOrderIds = append(makerOrderIds, result.Order.ID.Hex())
+	}
+
+	client.SetUser(2)
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodPost,
+		URL:    "/orders",
+		Body: trade.CreateOrder{
+			Symbol:   testSymbol,
+			Type:     models.BUY,
+			Price:    models.MustParseDecimal("101"),
+			Quantity: models.MustParseDecimal("2"),
+		},
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	result := trade.PlaceOrderResult{}
+	json.NewDecoder(res.Body).Decode(&result)
+	assert.Len(t, result.Fills, 2)
+	takerOrderId := result.Order.ID.Hex()
+
+	// Both sides see their fills, latest first
+	for _, userId := range []uint64{1, 2} {
+		client.SetUser(userId)
+		res = client.Request(&testutil.RequestOption{
+			Method: http.MethodGet,
+			URL:    "/trades",
+		})
+		assert.Equal(t, http.StatusOK, res.Code)
+		trades := make([]models.Trade, 0)
+		json.NewDecoder(res.Body).Decode(&trades)
+		assert.Len(t, trades, 2)
+		assert.Equal(t, uint64(2), trades[0].Sequence)
+		assert.Equal(t, makerOrderIds[1], trades[0].MakerOrderId.Hex())
+		assert.Equal(t, takerOrderId, trades[0].TakerOrderId.Hex())
+		assert.Equal(t, "101", trades[0].Price.String())
+		assert.Equal(t, models.BUY, trades[0].Side)
+		assert.Equal(t, uint64(1), trades[1].Sequence)
+		assert.Equal(t, makerOrderIds[0], trades[1].MakerOrderId.Hex())
+	}
+
+	client.SetUser(3)
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/trades",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	trades := make([]models.Trade, 0)
+	json.NewDecoder(res.Body).Decode(&trades)
+	assert.Len(t, trades, 0)
+
+	res = client.Request(&testutil.RequestOption{
+		Method: http.MethodGet,
+		URL:    "/markets/trades?symbol=" + testSymbol + "&limit=1",
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	trades = make([]models.Trade, 0)
+	json.NewDecoder(res.Body).Decode(&trades)
+	assert.Len(t, trades, 1)
+	assert.Equal(t, uint64(2), trades[0].Sequence)
+}
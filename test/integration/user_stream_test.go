@@ -0,0 +1,109 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"trading-bsx/cmd/api/server"
+	"trading-bsx/pkg/db/models"
+	"trading-bsx/pkg/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+func dialUserStream(httpServer *httptest.Server, client *testutil.Client, query string) (*websocket.Conn, error) {
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/ws/user"+query, httpServer.URL)
+	if err != nil {
+		return nil, err
+	}
+	config.Header.Set("Authorization", client.Authorization())
+	return websocket.DialConfig(config)
+}
+
+func receiveOrderEvent(t *testing.T, ws *websocket.Conn, eventType string, sequence uint64) models.Order {
+	msg := receiveMessage(t, ws)
+	assert.Equal(t, eventType, msg.Type)
+	assert.Equal(t, sequence, msg.Sequence)
+	order := models.Order{}
+	json.Unmarshal(msg.Data, &order)
+	return order
+}
+
+func Test_UserStream_OrderEventsAndReplay(t *testing.T) {
+	t.Setenv("ENV", "test")
+	s := server.New()
+	defer s.Close()
+	httpServer := httptest.NewServer(s)
+	defer httpServer.Close()
+	client := testutil.NewClient(s)
+	fundUsers(t, 1, 2, 3, 4)
+
+	client.SetUser(1)
+	ws, err := dialUserStream(httpServer, client, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := placeSell(t, client, "100", "2")
+	event := receiveOrderEvent(t, ws, "ORDER_ACCEPTED", 1)
+	assert.Equal(t, order.ID.Hex(), event.ID.Hex())
+	assert.Equal(t, models.NEW, event.Status)
+
+	client.SetUser(2)
+	code, buy := placeBuy(client, "100", "0.5", "")
+	assert.Equal(t, http.StatusOK, code)
+	msg := receiveMessage(t, ws)
+	assert.Equal(t, "FILL", msg.Type)
+	assert.Equal(t, uint64(2), msg.Sequence)
+	fill := models.Trade{}
+	json.Unmarshal(msg.Data, &fill)
+	assert.Equal(t, order.ID.Hex(), fill.MakerOrderId.Hex())
+	assert.Equal(t, buy.Order.ID.Hex(), fill.TakerOrderId.Hex())
+	assert.Equal(t, "0.5", fill.Quantity.String())
+	event = receiveOrderEvent(t, ws, "ORDER_PARTIALLY_FILLED", 3)
+	assert.Equal(t, "1.5", event.Remaining.String())
+
+	client.SetUser(1)
+	res := client.Request(&testutil.RequestOption{
+		Method: http.MethodDelete,
+		URL:    "/orders/" + order.ID.Hex(),
+	})
+	assert.Equal(t, http.StatusOK, res.Code)
+	event = receiveOrderEvent(t, ws, "ORDER_CANCELLED", 4)
+	assert.Equal(t, models.CANCELLED, event.Status)
+	ws.Close()
+
+	// Events missed while disconnected are replayed, then events are streamed live
+	placeSell(t, client, "101", "1")
+	ws, err = dialUserStream(httpServer, client, "?after=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	receiveOrderEvent(t, ws, "ORDER_PARTIALLY_FILLED", 3)
+	receiveOrderEvent(t, ws, "ORDER_CANCELLED", 4)
+	receiveOrderEvent(t, ws, "ORDER_ACCEPTED", 5)
+	placeSell(t, client, "102", "1")
+	event = receiveOrderEvent(t, ws, "ORDER_ACCEPTED", 6)
+	assert.Equal(t, "102", event.Price.String())
+
+	// The taker has its own sequence
+	client.SetUser(2)
+	ws2, err := dialUserStream(httpServer, client, "?after=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws2.Close()
+	receiveOrderEvent(t, ws2, "ORDER_ACCEPTED", 1)
+	msg = receiveMessage(t, ws2)
+	assert.Equal(t, "FILL", msg.Type)
+	assert.Equal(t, uint64(2), msg.Sequence)
+	event = receiveOrderEvent(t, ws2, "ORDER_FILLED", 3)
+	assert.Equal(t, buy.Order.ID.Hex(), event.ID.Hex())
+
+	// Connecting without credentials is rejected
+	_, err = websocket.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/ws/user", "", httpServer.URL)
+	assert.Error(t, err)
+}